@@ -0,0 +1,122 @@
+package swisstable
+
+import (
+	"errors"
+	"hash/maphash"
+)
+
+// Builder collects configuration for a SwissTable. Each With method returns
+// the builder so calls can be chained, and Build validates the combination.
+//
+//	st, err := NewBuilder().WithCapacity(1000).WithLoadFactor(0.5).Build()
+type Builder struct {
+	loadFactor float64
+	capacity   int
+	hash       func(key any) uint64
+	equal      func(a, b any) bool
+	seed       maphash.Seed
+	hasSeed    bool
+}
+
+// Options configures NewWithOptions. Zero fields keep the defaults of New.
+type Options struct {
+	// Fraction of slots that may be filled before the table grows, in (0, 1)
+	LoadFactor float64
+	// Factor the slot count is multiplied by on each resize, at least 2
+	GrowthFactor int
+	// Replaces maphash as the key hash when set, see Builder.WithHasher
+	Hash func(key any) uint64
+	// Replaces == for key comparison when set, needs Hash, see
+	// Builder.WithEquals
+	Equal func(a, b any) bool
+}
+
+// NewWithOptions creates a SwissTable configured by opts, returning an error
+// if a setting is out of range
+func NewWithOptions(opts Options) (*SwissTable, error) {
+	b := NewBuilder().WithHasher(opts.Hash).WithEquals(opts.Equal)
+	if opts.LoadFactor != 0 {
+		b.WithLoadFactor(opts.LoadFactor)
+	}
+	growth := growthFactor
+	if opts.GrowthFactor != 0 {
+		growth = opts.GrowthFactor
+	}
+	if growth < 2 {
+		return nil, errors.New("swisstable: growth factor must be at least 2")
+	}
+
+	st, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	st.growth = growth
+	return st, nil
+}
+
+// NewBuilder returns a builder with the same defaults as New
+func NewBuilder() *Builder {
+	return &Builder{loadFactor: loadFactor}
+}
+
+// WithLoadFactor sets the fraction of slots that may be filled before the
+// table grows. It must be in (0, 1).
+func (b *Builder) WithLoadFactor(f float64) *Builder {
+	b.loadFactor = f
+	return b
+}
+
+// WithCapacity sizes the table to hold n entries without resizing
+func (b *Builder) WithCapacity(n int) *Builder {
+	b.capacity = n
+	return b
+}
+
+// WithHasher replaces maphash with a custom hash function. H1 and H2 are
+// still split from the returned 64 bits, so the function should mix well.
+func (b *Builder) WithHasher(hash func(key any) uint64) *Builder {
+	b.hash = hash
+	return b
+}
+
+// WithEquals replaces == for key comparison. Keys that are equal under it
+// must hash identically.
+func (b *Builder) WithEquals(equal func(a, b any) bool) *Builder {
+	b.equal = equal
+	return b
+}
+
+// WithSeed fixes the maphash seed, so tables built with the same seed place
+// the same keys identically. It has no effect together with WithHasher.
+func (b *Builder) WithSeed(seed maphash.Seed) *Builder {
+	b.seed = seed
+	b.hasSeed = true
+	return b
+}
+
+// Build validates the configuration and creates the table
+func (b *Builder) Build() (*SwissTable, error) {
+	if !(b.loadFactor > 0 && b.loadFactor < 1) {
+		return nil, errors.New("swisstable: load factor must be in (0, 1)")
+	}
+	if b.capacity < 0 {
+		return nil, errors.New("swisstable: capacity must not be negative")
+	}
+	if b.equal != nil && b.hash == nil {
+		return nil, errors.New("swisstable: a custom equality needs a matching hasher")
+	}
+
+	st := New()
+	st.maxLoad = b.loadFactor
+	st.hash = b.hash
+	st.equal = b.equal
+	if b.hasSeed {
+		st.hashSeed = b.seed
+	}
+	if slots := st.capacityFor(b.capacity); slots > len(st.entries) {
+		if err := st.resizeTo(slots); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
@@ -0,0 +1,222 @@
+package swisstable
+
+import (
+	"bytes"
+	"fmt"
+	"hash/maphash"
+	"strings"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	seed := maphash.MakeSeed()
+	hashCalls := 0
+	foldHash := func(key any) uint64 {
+		hashCalls++
+		return maphash.String(seed, strings.ToLower(key.(string)))
+	}
+	st, err := NewBuilder().
+		WithLoadFactor(0.5).
+		WithCapacity(100).
+		WithHasher(foldHash).
+		WithEquals(func(a, b any) bool {
+			return strings.EqualFold(a.(string), b.(string))
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if st.maxLoad != 0.5 {
+		t.Errorf("Expected load factor 0.5, got %v", st.maxLoad)
+	}
+	if len(st.entries) != 208 {
+		t.Errorf("Expected 208 slots for 100 entries at 0.5, got %d", len(st.entries))
+	}
+
+	st.Put("Hello", 1)
+	if hashCalls == 0 {
+		t.Error("Expected the custom hasher to be used")
+	}
+	if v, ok := st.Get("HELLO"); !ok || v != 1 {
+		t.Errorf("Expected case-insensitive hit (1, true), got (%v, %v)", v, ok)
+	}
+	st.Put("hello", 2)
+	if st.Size() != 1 {
+		t.Errorf("Expected overwrite under custom equality, size is %d", st.Size())
+	}
+}
+
+func TestBuilderSeed(t *testing.T) {
+	seed := maphash.MakeSeed()
+	a, err := NewBuilder().WithSeed(seed).Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	b, _ := NewBuilder().WithSeed(seed).Build()
+
+	for i := 0; i < 50; i++ {
+		a.Put(i, i)
+		b.Put(i, i)
+	}
+	for i := 0; i < 50; i++ {
+		slotA, _ := a.SlotOf(i)
+		slotB, _ := b.SlotOf(i)
+		if slotA != slotB {
+			t.Errorf("Key %d: slot %d vs %d under the same seed", i, slotA, slotB)
+		}
+	}
+}
+
+func TestBuilderValidation(t *testing.T) {
+	for name, b := range map[string]*Builder{
+		"zero load factor":    NewBuilder().WithLoadFactor(0),
+		"full load factor":    NewBuilder().WithLoadFactor(1),
+		"negative capacity":   NewBuilder().WithCapacity(-1),
+		"equality, no hasher": NewBuilder().WithEquals(func(a, b any) bool { return a == b }),
+	} {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("%s: expected Build to fail", name)
+		}
+	}
+}
+
+// resizesDuring counts the resizes while inserting n keys into st
+func resizesDuring(st *SwissTable, n int) int {
+	resizes := 0
+	for i := 0; i < n; i++ {
+		capacity := len(st.entries)
+		st.Put(i, i)
+		if len(st.entries) != capacity {
+			resizes++
+		}
+	}
+	return resizes
+}
+
+func TestNewWithOptions(t *testing.T) {
+	def, err := NewWithOptions(Options{})
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %v", err)
+	}
+	if def.maxLoad != loadFactor || def.growth != growthFactor {
+		t.Errorf("Expected the defaults (%v, %d), got (%v, %d)", loadFactor, growthFactor, def.maxLoad, def.growth)
+	}
+
+	// 12 keys fill 16 slots to exactly 0.75, a stricter limit grows sooner
+	sparse, _ := NewWithOptions(Options{LoadFactor: 0.5})
+	if got := resizesDuring(def, 12); got != 0 {
+		t.Errorf("Expected no resize for 12 keys at the default load factor, got %d", got)
+	}
+	if got := resizesDuring(sparse, 12); got == 0 {
+		t.Error("Expected a load factor of 0.5 to resize within 12 keys")
+	}
+
+	def, _ = NewWithOptions(Options{})
+	wide, err := NewWithOptions(Options{GrowthFactor: 8})
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %v", err)
+	}
+	defResizes, wideResizes := resizesDuring(def, 10000), resizesDuring(wide, 10000)
+	if wideResizes >= defResizes {
+		t.Errorf("Expected growth factor 8 to resize less often than 2, got %d vs %d", wideResizes, defResizes)
+	}
+	if got, want := wide.NextCapacity(), wide.Cap()*8; got != want {
+		t.Errorf("Expected next capacity %d, got %d", want, got)
+	}
+	checkInvariants(t, wide)
+
+	for name, opts := range map[string]Options{
+		"load factor of 1":   {LoadFactor: 1},
+		"negative load":      {LoadFactor: -0.5},
+		"growth factor of 1": {GrowthFactor: 1},
+		"negative growth":    {GrowthFactor: -2},
+	} {
+		if _, err := NewWithOptions(opts); err == nil {
+			t.Errorf("%s: expected NewWithOptions to fail", name)
+		}
+	}
+}
+
+func TestNewWithOptionsHash(t *testing.T) {
+	// FNV-1a over the decimal form, the same in every process
+	fnv := func(key any) uint64 {
+		h := uint64(14695981039346656037)
+		for _, c := range []byte(fmt.Sprint(key)) {
+			h = (h ^ uint64(c)) * 1099511628211
+		}
+		return h
+	}
+	constant := func(key any) uint64 { return 7 }
+
+	for name, hash := range map[string]func(key any) uint64{"fnv": fnv, "constant": constant} {
+		t.Run(name, func(t *testing.T) {
+			st, err := NewWithOptions(Options{Hash: hash})
+			if err != nil {
+				t.Fatalf("NewWithOptions failed: %v", err)
+			}
+			for i := 0; i < 100; i++ {
+				st.Put(i, i*2)
+			}
+			st.Delete(50)
+			for i := 0; i < 100; i++ {
+				v, ok := st.Get(i)
+				if want := i != 50; ok != want || (ok && v != i*2) {
+					t.Errorf("Key %d: expected present=%v with %d, got (%v, %v)", i, want, i*2, v, ok)
+				}
+			}
+			checkInvariants(t, st)
+		})
+	}
+
+	// The same hasher places keys identically in independent tables
+	a, _ := NewWithOptions(Options{Hash: fnv})
+	b, _ := NewWithOptions(Options{Hash: fnv})
+	for i := 0; i < 50; i++ {
+		a.Put(i, i)
+		b.Put(i, i)
+	}
+	if a.Visualize() != b.Visualize() {
+		t.Error("Expected identical layouts under a deterministic hasher")
+	}
+}
+
+func TestNewWithOptionsEqual(t *testing.T) {
+	seed := maphash.MakeSeed()
+	st, err := NewWithOptions(Options{
+		Hash:  func(key any) uint64 { return maphash.Bytes(seed, key.([]byte)) },
+		Equal: func(a, b any) bool { return bytes.Equal(a.([]byte), b.([]byte)) },
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %v", err)
+	}
+
+	// Enough keys to resize several times, each a fresh slice
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key-%d", i)) }
+	for i := 0; i < 500; i++ {
+		st.Put(key(i), i)
+	}
+	st.Put(key(7), "seven")
+	if st.Size() != 500 {
+		t.Errorf("Expected 500 keys with one overwrite, got %d", st.Size())
+	}
+	if !st.Delete(key(8)) || st.Contains(key(8)) {
+		t.Error("Expected an equal byte slice to delete the key")
+	}
+	for i := 0; i < 500; i++ {
+		if i == 8 {
+			continue
+		}
+		var want any = i
+		if i == 7 {
+			want = "seven"
+		}
+		if v, ok := st.Get(key(i)); !ok || v != want {
+			t.Errorf("Key %q: expected (%v, true), got (%v, %v)", key(i), want, v, ok)
+		}
+	}
+
+	if _, err := NewWithOptions(Options{Equal: func(a, b any) bool { return a == b }}); err == nil {
+		t.Error("Expected a custom equality without a hasher to fail")
+	}
+}
@@ -0,0 +1,86 @@
+package swisstable
+
+import "sync"
+
+// ConcurrentSwissTable is a SwissTable that is safe for concurrent use. Reads
+// share a read lock and writes take the write lock, so readers only wait for
+// writers. All operations go through one lock; it suits read-heavy loads.
+type ConcurrentSwissTable struct {
+	mu    sync.RWMutex
+	table *SwissTable
+}
+
+// NewConcurrent creates an empty ConcurrentSwissTable
+func NewConcurrent() *ConcurrentSwissTable {
+	return &ConcurrentSwissTable{table: New()}
+}
+
+// Get retrieves a value by key
+func (ct *ConcurrentSwissTable) Get(key any) (any, bool) {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+	return ct.table.Get(key)
+}
+
+// Put inserts or updates a key-value pair
+func (ct *ConcurrentSwissTable) Put(key, value any) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.table.Put(key, value)
+}
+
+// GetVersioned is Get that also returns the entry's version: the table
+// generation of its last write, which grows with every overwrite and never
+// repeats for a key, even after it is deleted and put again. A missing key
+// reports version 0.
+func (ct *ConcurrentSwissTable) GetVersioned(key any) (value any, version uint64, ok bool) {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+	return ct.versioned(key)
+}
+
+// PutIfVersion stores the pair only if the key's version still equals
+// expected, as read by GetVersioned, and reports whether it did. An expected
+// version of 0 succeeds only while the key is absent. Callers can compute a
+// new value without holding any lock and retry from GetVersioned on failure.
+func (ct *ConcurrentSwissTable) PutIfVersion(key, value any, expected uint64) bool {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if _, version, _ := ct.versioned(key); version != expected {
+		return false
+	}
+	ct.table.Put(key, value)
+	return true
+}
+
+// versioned returns the value and version of key; the caller holds the lock
+func (ct *ConcurrentSwissTable) versioned(key any) (any, uint64, bool) {
+	_, idx, found := ct.table.lookup(key, 0)
+	if !found {
+		return nil, 0, false
+	}
+	return ct.table.entries[idx].value, ct.table.entries[idx].gen, true
+}
+
+// Delete removes a key-value pair
+func (ct *ConcurrentSwissTable) Delete(key any) bool {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return ct.table.Delete(key)
+}
+
+// Size returns the number of elements in the table
+func (ct *ConcurrentSwissTable) Size() int {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+	return ct.table.Size()
+}
+
+// Range calls f for every live entry under the read lock, stopping early if f
+// returns false. Writers wait until Range returns, so f must not call Put or
+// Delete on the same table, which would deadlock.
+func (ct *ConcurrentSwissTable) Range(f func(key, value any) bool) {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+	ct.table.Range(f)
+}
@@ -0,0 +1,96 @@
+package swisstable
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestConcurrentSwissTable mixes operations from many goroutines, run it with
+// -race to check the locking
+func TestConcurrentSwissTable(t *testing.T) {
+	ct := NewConcurrent()
+	ct.StressTest(t, 16, 2000)
+
+	live := 0
+	ct.Range(func(key, value any) bool {
+		live++
+		return true
+	})
+	if live != ct.Size() {
+		t.Errorf("Range visited %d entries, Size reports %d", live, ct.Size())
+	}
+	checkInvariants(t, ct.table)
+}
+
+func TestPutIfVersion(t *testing.T) {
+	ct := NewConcurrent()
+	if !ct.PutIfVersion("k", 1, 0) {
+		t.Fatal("Expected version 0 to create an absent key")
+	}
+	if ct.PutIfVersion("k", 2, 0) {
+		t.Error("Expected version 0 to fail once the key exists")
+	}
+	v, version, ok := ct.GetVersioned("k")
+	if !ok || v != 1 || version == 0 {
+		t.Fatalf("Expected (1, >0, true), got (%v, %d, %v)", v, version, ok)
+	}
+
+	// A write in between makes the version read earlier stale
+	ct.Put("k", 5)
+	if ct.PutIfVersion("k", 2, version) {
+		t.Error("Expected a stale version to be rejected")
+	}
+	if v, _ := ct.Get("k"); v != 5 {
+		t.Errorf("Expected the rejected update to leave 5, got %v", v)
+	}
+	ct.Delete("k")
+	ct.Put("k", 1)
+	if _, again, _ := ct.GetVersioned("k"); again == version {
+		t.Error("Expected a deleted and re-put key to get a new version")
+	}
+
+	// Racing updates from the same read: exactly one wins, the rest are stale
+	_, version, _ = ct.GetVersioned("k")
+	var won atomic.Int64
+	var race sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		race.Add(1)
+		go func(g int) {
+			defer race.Done()
+			if ct.PutIfVersion("k", g, version) {
+				won.Add(1)
+			}
+		}(g)
+	}
+	race.Wait()
+	if n := won.Load(); n != 1 {
+		t.Errorf("Expected exactly one racing update to win, %d did", n)
+	}
+
+	// Optimistic increments from many goroutines lose no update: every
+	// increment computed from a stale read is rejected and retried
+	const goroutines, increments = 8, 200
+	ct.Put("counter", 0)
+	var rejected atomic.Int64
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				for {
+					v, version, _ := ct.GetVersioned("counter")
+					if ct.PutIfVersion("counter", v.(int)+1, version) {
+						break
+					}
+					rejected.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := ct.Get("counter"); v != goroutines*increments {
+		t.Errorf("Expected counter %d, got %v (%d stale updates rejected)", goroutines*increments, v, rejected.Load())
+	}
+}
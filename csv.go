@@ -0,0 +1,26 @@
+package swisstable
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// WriteCSV writes a "key,value" header followed by one row per live entry in
+// slot order, formatting keys and values with fmt.Sprint
+func (st *SwissTable) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"key", "value"}); err != nil {
+		return err
+	}
+	var err error
+	st.Range(func(key, value any) bool {
+		err = cw.Write([]string{fmt.Sprint(key), fmt.Sprint(value)})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,42 @@
+package swisstable
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	st := New()
+	want := map[string]string{
+		"1":           "one",
+		"comma,key":   "quoted \"value\"",
+		"multi\nline": "x",
+	}
+	for k, v := range want {
+		st.Put(k, v)
+	}
+	st.Put(7, 3.5)
+	want["7"] = "3.5"
+
+	var buf bytes.Buffer
+	if err := st.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Reading CSV back failed: %v", err)
+	}
+	if len(rows) != len(want)+1 {
+		t.Fatalf("Expected %d rows including the header, got %d", len(want)+1, len(rows))
+	}
+	if rows[0][0] != "key" || rows[0][1] != "value" {
+		t.Errorf("Expected header key,value, got %v", rows[0])
+	}
+	for _, row := range rows[1:] {
+		if want[row[0]] != row[1] {
+			t.Errorf("Key %q: expected %q, got %q", row[0], want[row[0]], row[1])
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package swisstable
+
+import "reflect"
+
+// valueStore holds one canonical copy of each distinct value together with
+// the number of entries referring to it
+type valueStore struct {
+	hashValue func(value any) uint64
+	// Canonical values by content hash; a slice because hashes may collide
+	values map[uint64][]*sharedValue
+}
+
+// sharedValue is a canonical value and its reference count
+type sharedValue struct {
+	value any
+	refs  int
+}
+
+// NewDedupValues creates a SwissTable that stores each distinct value once,
+// for caches where many keys share large values. Values are identified by
+// hashValue and confirmed with reflect.DeepEqual, and every entry holding an
+// equal value refers to the first copy put. A value is released once the
+// last entry referring to it is deleted or overwritten.
+func NewDedupValues(hashValue func(value any) uint64) *SwissTable {
+	st := New()
+	st.dedup = &valueStore{hashValue: hashValue, values: make(map[uint64][]*sharedValue)}
+	return st
+}
+
+// acquire returns the canonical copy of value, taking a reference to it
+func (vs *valueStore) acquire(value any) any {
+	h := vs.hashValue(value)
+	for _, sv := range vs.values[h] {
+		if reflect.DeepEqual(sv.value, value) {
+			sv.refs++
+			return sv.value
+		}
+	}
+	vs.values[h] = append(vs.values[h], &sharedValue{value: value, refs: 1})
+	return value
+}
+
+// release drops a reference to a canonical value, forgetting it with the
+// last reference
+func (vs *valueStore) release(value any) {
+	h := vs.hashValue(value)
+	bucket := vs.values[h]
+	for i, sv := range bucket {
+		if reflect.DeepEqual(sv.value, value) {
+			if sv.refs--; sv.refs == 0 {
+				bucket = append(bucket[:i], bucket[i+1:]...)
+				if len(bucket) == 0 {
+					delete(vs.values, h)
+				} else {
+					vs.values[h] = bucket
+				}
+			}
+			return
+		}
+	}
+}
+
+// clone returns a copy of the store with its own reference counts
+func (vs *valueStore) clone() *valueStore {
+	c := &valueStore{hashValue: vs.hashValue, values: make(map[uint64][]*sharedValue, len(vs.values))}
+	for h, bucket := range vs.values {
+		copied := make([]*sharedValue, len(bucket))
+		for i, sv := range bucket {
+			copied[i] = &sharedValue{value: sv.value, refs: sv.refs}
+		}
+		c.values[h] = copied
+	}
+	return c
+}
+
+// unique returns the number of distinct values stored
+func (vs *valueStore) unique() int {
+	n := 0
+	for _, bucket := range vs.values {
+		n += len(bucket)
+	}
+	return n
+}
@@ -0,0 +1,45 @@
+package swisstable
+
+import (
+	"hash/maphash"
+	"testing"
+)
+
+func TestNewDedupValues(t *testing.T) {
+	seed := maphash.MakeSeed()
+	st := NewDedupValues(func(value any) uint64 {
+		return maphash.Bytes(seed, value.([]byte))
+	})
+
+	blob := make([]byte, 1<<16)
+	for i := range blob {
+		blob[i] = byte(i)
+	}
+	st.Put("a", blob)
+	st.Put("b", append([]byte(nil), blob...))
+	st.Put("c", []byte("small"))
+
+	if n := st.dedup.unique(); n != 2 {
+		t.Errorf("Expected 2 distinct values stored, got %d", n)
+	}
+	a, _ := st.Get("a")
+	b, _ := st.Get("b")
+	if &a.([]byte)[0] != &b.([]byte)[0] {
+		t.Error("Expected both keys to share one copy of the blob")
+	}
+
+	// The copy is kept until its last reference goes away
+	st.Delete("a")
+	if n := st.dedup.unique(); n != 2 {
+		t.Errorf("Expected the blob to survive one delete, got %d distinct values", n)
+	}
+	st.Put("b", []byte("small"))
+	if n := st.dedup.unique(); n != 1 {
+		t.Errorf("Expected the blob to be released after its last overwrite, got %d distinct values", n)
+	}
+	st.Delete("b")
+	st.Delete("c")
+	if n := st.dedup.unique(); n != 0 {
+		t.Errorf("Expected an empty store, got %d distinct values", n)
+	}
+}
@@ -0,0 +1,141 @@
+package swisstable
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math/bits"
+	"sort"
+)
+
+// Number of seeds BuildPerfect tries
+const perfectSeedAttempts = 8
+
+// perfectSeed supplies the candidate seeds for BuildPerfect, tests replace it
+// to make the search deterministic
+var perfectSeed = maphash.MakeSeed
+
+// FrozenTable is an immutable snapshot of a SwissTable laid out for lookups.
+// Entries are placed in home-group order and never deleted, so probe chains
+// are as short as linear probing allows and hold no tombstones.
+type FrozenTable struct {
+	table *SwissTable
+}
+
+// Freeze marks the table immutable and returns a read-only view of it. Any
+// later call that would modify the original table panics.
+func (st *SwissTable) Freeze() *FrozenTable {
+	type placed struct {
+		e    entry
+		home uint64
+	}
+
+	live := make([]placed, 0, st.size)
+	for idx, e := range st.entries {
+		if !isFull(st.metadata[idx/groupSize].bytes[idx%groupSize]) {
+			continue
+		}
+		h1, _ := st.hashKey(e.key)
+		live = append(live, placed{e: e, home: h1})
+	}
+
+	ft := st.newLike()
+	// ft is empty and not yet frozen, so sizing it up front cannot fail
+	_ = ft.resizeTo(ft.capacityFor(len(live)))
+
+	// Inserting in home-group order keeps every entry as close to home as
+	// linear probing allows
+	sort.SliceStable(live, func(i, j int) bool {
+		return ft.homeGroup(live[i].home) < ft.homeGroup(live[j].home)
+	})
+	for _, p := range live {
+		_, h2 := ft.hashKey(p.e.key)
+		idx, _, _ := ft.putHashed(p.e.key, p.e.value, p.home, h2)
+		ft.entries[idx] = p.e
+	}
+
+	st.frozen = true
+	ft.frozen = true
+	return &FrozenTable{table: ft}
+}
+
+// Get retrieves a value by key
+func (ft *FrozenTable) Get(key any) (any, bool) {
+	st := ft.table
+	h1, h2 := st.hashKey(key)
+	groupIdx := st.homeGroup(h1)
+
+	for range st.probeSpan() {
+		group := &st.metadata[groupIdx]
+		for matches := matchGroup(group, h2); matches != 0; matches &= matches - 1 {
+			idx := int(groupIdx)*groupSize + bits.TrailingZeros16(matches)
+			if st.keysEqual(st.entries[idx].key, key) {
+				return st.entries[idx].value, true
+			}
+		}
+		// Nothing is ever deleted, so an empty slot ends the probe sequence
+		if matchEmpty(group) != 0 {
+			break
+		}
+		groupIdx = st.nextGroup(groupIdx)
+	}
+	return nil, false
+}
+
+// Size returns the number of elements in the table
+func (ft *FrozenTable) Size() int {
+	return ft.table.size
+}
+
+// HasFingerprintCollisions reports whether any two keys share both their
+// home group and H2 under seed, in a table sized for len(keys) at the default
+// load factor. Such keys always need a full key compare to tell apart, so
+// callers building near-perfect tables can search for a seed without any.
+// A repeated key counts as a collision.
+func HasFingerprintCollisions(keys []any, seed maphash.Seed) bool {
+	st := &SwissTable{hashSeed: seed, maxLoad: loadFactor}
+	st.groupCount = st.capacityFor(len(keys)) / groupSize
+
+	type slot struct {
+		group uint64
+		h2    uint8
+	}
+	seen := make(map[slot]bool, len(keys))
+	for _, key := range keys {
+		h1, h2 := st.hashKey(key)
+		s := slot{st.homeGroup(h1), h2}
+		if seen[s] {
+			return true
+		}
+		seen[s] = true
+	}
+	return false
+}
+
+// BuildPerfect creates a frozen table from a fixed key set, trying a bounded
+// number of hash seeds and keeping the one with the shortest maximum probe
+// length, ties broken by the shorter average. Lookups on the result are
+// consistently fast; any modification panics. It fails if a key repeats.
+func BuildPerfect(pairs ...[2]any) (*SwissTable, error) {
+	var best *SwissTable
+	bestAvg, bestLongest := 0.0, 0
+	for range perfectSeedAttempts {
+		st := New()
+		st.hashSeed = perfectSeed()
+		if err := st.resizeTo(st.capacityFor(len(pairs))); err != nil {
+			return nil, err
+		}
+		for _, p := range pairs {
+			st.Put(p[0], p[1])
+		}
+		if st.size != len(pairs) {
+			return nil, fmt.Errorf("swisstable: %d repeated keys in perfect build", len(pairs)-st.size)
+		}
+
+		avg, longest := st.probeStats()
+		if best == nil || longest < bestLongest || (longest == bestLongest && avg < bestAvg) {
+			best, bestAvg, bestLongest = st, avg, longest
+		}
+	}
+	best.frozen = true
+	return best, nil
+}
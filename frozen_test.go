@@ -0,0 +1,176 @@
+package swisstable
+
+import (
+	"fmt"
+	"hash/maphash"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	st := New()
+	for i := 0; i < 500; i++ {
+		st.Put(i, fmt.Sprint(i))
+	}
+	for i := 0; i < 500; i += 7 {
+		st.Delete(i)
+	}
+
+	ft := st.Freeze()
+	if ft.Size() != st.Size() {
+		t.Errorf("Expected frozen size %d, got %d", st.Size(), ft.Size())
+	}
+	for i := 0; i < 600; i++ {
+		want, wantOk := st.Get(i)
+		if got, ok := ft.Get(i); ok != wantOk || got != want {
+			t.Errorf("Key %d: expected (%v, %v), got (%v, %v)", i, want, wantOk, got, ok)
+		}
+	}
+}
+
+func TestFreezeRejectsMutation(t *testing.T) {
+	for name, mutate := range map[string]func(*SwissTable){
+		"Put":    func(st *SwissTable) { st.Put(2, "two") },
+		"Delete": func(st *SwissTable) { st.Delete(1) },
+		"Repair": func(st *SwissTable) { st.Repair() },
+	} {
+		t.Run(name, func(t *testing.T) {
+			st := New()
+			st.Put(1, "one")
+			st.Freeze()
+
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %s on a frozen table to panic", name)
+				}
+				if v, ok := st.Get(1); !ok || v != "one" {
+					t.Errorf("Expected frozen table to keep (one, true), got (%v, %v)", v, ok)
+				}
+			}()
+			mutate(st)
+		})
+	}
+}
+
+func TestHasFingerprintCollisions(t *testing.T) {
+	keys := make([]any, 12)
+	for i := range keys {
+		keys[i] = i
+	}
+
+	// Twelve keys share one group, so about 40% of seeds make two of them
+	// agree on H2
+	var bad, good []maphash.Seed
+	for range 1000 {
+		seed := maphash.MakeSeed()
+		if HasFingerprintCollisions(keys, seed) {
+			bad = append(bad, seed)
+		} else {
+			good = append(good, seed)
+		}
+	}
+	if len(bad) == 0 || len(good) == 0 {
+		t.Fatalf("Expected both colliding and collision-free seeds, got %d and %d", len(bad), len(good))
+	}
+
+	// Check the verdicts against the layout a table with each seed produces
+	for seed, want := range map[maphash.Seed]bool{bad[0]: true, good[0]: false} {
+		st, _ := NewBuilder().WithSeed(seed).WithCapacity(len(keys)).Build()
+		seen := make(map[[2]uint64]bool)
+		collides := false
+		for _, k := range keys {
+			h1, h2 := st.hashKey(k)
+			s := [2]uint64{st.homeGroup(h1), uint64(h2)}
+			collides = collides || seen[s]
+			seen[s] = true
+		}
+		if collides != want {
+			t.Errorf("Expected collisions=%v for the seed, the table layout says %v", want, collides)
+		}
+	}
+
+	if !HasFingerprintCollisions([]any{collidingKey(1), collidingKey(2)}, good[0]) {
+		t.Error("Expected keys with identical hashes to collide under any seed")
+	}
+	if !HasFingerprintCollisions([]any{"dup", "dup"}, good[0]) {
+		t.Error("Expected a repeated key to count as a collision")
+	}
+}
+
+func TestBuildPerfect(t *testing.T) {
+	pairs := make([][2]any, 3000)
+	for i := range pairs {
+		pairs[i] = [2]any{i, i * 10}
+	}
+
+	// The default build's seed is the first candidate, so the search can
+	// only match or beat it
+	seeds := []maphash.Seed{maphash.MakeSeed()}
+	for len(seeds) < perfectSeedAttempts {
+		seeds = append(seeds, maphash.MakeSeed())
+	}
+	next := 0
+	perfectSeed = func() maphash.Seed { next++; return seeds[next-1] }
+	defer func() { perfectSeed = maphash.MakeSeed }()
+
+	def := New()
+	def.hashSeed = seeds[0]
+	def.resizeTo(def.capacityFor(len(pairs)))
+	for _, p := range pairs {
+		def.Put(p[0], p[1])
+	}
+
+	st, err := BuildPerfect(pairs...)
+	perfectSeed = maphash.MakeSeed
+	if err != nil {
+		t.Fatalf("BuildPerfect failed: %v", err)
+	}
+	_, defLongest := def.probeStats()
+	if _, longest := st.probeStats(); longest > defLongest {
+		t.Errorf("Expected max probe at most %d, got %d", defLongest, longest)
+	}
+	for _, p := range pairs {
+		if v, ok := st.Get(p[0]); !ok || v != p[1] {
+			t.Errorf("Key %v: expected (%v, true), got (%v, %v)", p[0], p[1], v, ok)
+		}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected Put on a perfect table to panic")
+			}
+		}()
+		st.Put(-1, 0)
+	}()
+
+	if _, err := BuildPerfect([2]any{1, 1}, [2]any{1, 2}); err == nil {
+		t.Error("Expected an error for a repeated key")
+	}
+}
+
+func BenchmarkFrozenGet(b *testing.B) {
+	st := New()
+	for i := 0; i < 4096; i++ {
+		st.Put(i, i)
+	}
+	ft := st.Freeze()
+
+	for _, tc := range []struct {
+		name string
+		get  func(key any) (any, bool)
+	}{
+		{"mutable", st.Get},
+		{"frozen", ft.Get},
+	} {
+		b.Run(tc.name+"/hit", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tc.get(i % 4096)
+			}
+		})
+		b.Run(tc.name+"/miss", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tc.get(4096 + i%4096)
+			}
+		})
+	}
+}
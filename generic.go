@@ -0,0 +1,239 @@
+package swisstable
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math/bits"
+	"reflect"
+)
+
+// Table is a Swiss Table with typed keys and values. It uses the same group
+// layout and control bytes as SwissTable, but stores keys and values unboxed
+// and hashes keys with maphash.Comparable, so the hot path neither allocates
+// nor compares interfaces. Get returns the zero V on a miss.
+type Table[K comparable, V any] struct {
+	// The actual key-value pairs
+	entries []typedEntry[K, V]
+	// Control bytes, organized in SIMD-friendly groups
+	metadata []metadata
+	// Number of elements in the table
+	size int
+	// Hash seed for maphash.Comparable
+	hashSeed maphash.Seed
+	// Number of groups (len(metadata))
+	groupCount int
+	// Number of ctrlDeleted slots, counted toward the load factor
+	tombstones int
+}
+
+// typedEntry is a key-value pair in a Table
+type typedEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// NewTable creates a new Table with initial capacity
+func NewTable[K comparable, V any]() *Table[K, V] {
+	t := &Table[K, V]{hashSeed: maphash.MakeSeed()}
+	t.init(initialSize)
+	return t
+}
+
+// init replaces the storage with slots empty slots
+func (t *Table[K, V]) init(slots int) {
+	t.entries = make([]typedEntry[K, V], slots)
+	t.metadata = make([]metadata, slots/groupSize)
+	t.groupCount = slots / groupSize
+	t.size = 0
+	t.tombstones = 0
+	for i := range t.metadata {
+		for j := range t.metadata[i].bytes {
+			t.metadata[i].bytes[j] = ctrlEmpty
+		}
+	}
+}
+
+// hashKey generates both H1 (group index) and H2 (metadata) hashes
+func (t *Table[K, V]) hashKey(key K) (h1 uint64, h2 uint8) {
+	hash := maphash.Comparable(t.hashSeed, key)
+	return hash >> h2Bits, uint8(hash & h2Mask)
+}
+
+// lookup returns the slot holding key and true, or false if it is absent
+func (t *Table[K, V]) lookup(key K, h1 uint64, h2 uint8) (int, bool) {
+	home := h1 % uint64(t.groupCount)
+	for groupIdx := home; ; {
+		group := &t.metadata[groupIdx]
+		for matches := matchGroup(group, h2); matches != 0; matches &= matches - 1 {
+			idx := int(groupIdx)*groupSize + bits.TrailingZeros16(matches)
+			if t.entries[idx].key == key {
+				return idx, true
+			}
+		}
+		// Inserts fill the first free slot, so the key cannot be further on
+		if matchEmpty(group) != 0 {
+			return -1, false
+		}
+		groupIdx = (groupIdx + 1) % uint64(t.groupCount)
+		if groupIdx == home {
+			return -1, false
+		}
+	}
+}
+
+// findSlot returns the slot holding key and true, or the first free slot in
+// its probe sequence and false
+func (t *Table[K, V]) findSlot(key K, h1 uint64, h2 uint8) (int, bool) {
+	if idx, found := t.lookup(key, h1, h2); found {
+		return idx, true
+	}
+
+	home := h1 % uint64(t.groupCount)
+	for groupIdx := home; ; {
+		if free := matchEmptyOrDeleted(&t.metadata[groupIdx]); free != 0 {
+			return int(groupIdx)*groupSize + bits.TrailingZeros16(free), false
+		}
+		groupIdx = (groupIdx + 1) % uint64(t.groupCount)
+		if groupIdx == home {
+			return -1, false
+		}
+	}
+}
+
+// resize reinserts every live entry into slots slots, which also drops every
+// tombstone
+func (t *Table[K, V]) resize(slots int) {
+	oldEntries, oldMetadata := t.entries, t.metadata
+	t.init(slots)
+	for groupIdx, group := range oldMetadata {
+		for byteIdx, h2 := range group.bytes {
+			if isFull(h2) {
+				e := oldEntries[groupIdx*groupSize+byteIdx]
+				t.Put(e.key, e.value)
+			}
+		}
+	}
+}
+
+// Put inserts or updates a key-value pair
+func (t *Table[K, V]) Put(key K, value V) {
+	// Tombstones count toward the load factor like SwissTable's, and a table
+	// filled mostly by them is rehashed in place instead of grown
+	if float64(t.size+t.tombstones+1)/float64(len(t.entries)) > loadFactor {
+		if t.tombstones >= t.size {
+			t.resize(len(t.entries))
+		} else {
+			t.resize(len(t.entries) * growthFactor)
+		}
+	}
+
+	h1, h2 := t.hashKey(key)
+	idx, found := t.findSlot(key, h1, h2)
+	if !found {
+		t.size++
+		if t.metadata[idx/groupSize].bytes[idx%groupSize] == ctrlDeleted {
+			t.tombstones--
+		}
+	}
+	t.entries[idx] = typedEntry[K, V]{key: key, value: value}
+	t.metadata[idx/groupSize].bytes[idx%groupSize] = h2
+}
+
+// Get retrieves a value by key, returning the zero V if it is absent
+func (t *Table[K, V]) Get(key K) (V, bool) {
+	h1, h2 := t.hashKey(key)
+	if idx, found := t.lookup(key, h1, h2); found {
+		return t.entries[idx].value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Delete removes a key-value pair
+func (t *Table[K, V]) Delete(key K) bool {
+	h1, h2 := t.hashKey(key)
+	idx, found := t.lookup(key, h1, h2)
+	if !found {
+		return false
+	}
+	// As in SwissTable.deleteAt, only a full group needs a tombstone to keep
+	// its probe chain intact
+	group := &t.metadata[idx/groupSize]
+	if matchEmpty(group) != 0 {
+		group.bytes[idx%groupSize] = ctrlEmpty
+	} else {
+		group.bytes[idx%groupSize] = ctrlDeleted
+		t.tombstones++
+	}
+	t.entries[idx] = typedEntry[K, V]{}
+	t.size--
+	return true
+}
+
+// Size returns the number of elements in the table
+func (t *Table[K, V]) Size() int {
+	return t.size
+}
+
+// Entries returns the live keys and values in slot order as two aligned
+// slices: values[i] is the value stored under keys[i]. Both are empty rather
+// than nil for an empty table.
+func (t *Table[K, V]) Entries() ([]K, []V) {
+	keys := make([]K, 0, t.size)
+	values := make([]V, 0, t.size)
+	for idx, e := range t.entries {
+		if isFull(t.metadata[idx/groupSize].bytes[idx%groupSize]) {
+			keys = append(keys, e.key)
+			values = append(values, e.value)
+		}
+	}
+	return keys, values
+}
+
+// ToGeneric copies an any-keyed table into a new Table[K, V]. It fails on the
+// first key that is not a K or value that is not a V; a nil value is accepted
+// only when V is an interface type. Settings such as custom hashing,
+// validators and metadata do not carry over.
+func ToGeneric[K comparable, V any](st *SwissTable) (*Table[K, V], error) {
+	t := NewTable[K, V]()
+	var err error
+	st.Range(func(key, value any) bool {
+		k, ok := assertAs[K](key)
+		if !ok {
+			err = fmt.Errorf("swisstable: key %v is %T, not %v", key, key, reflect.TypeFor[K]())
+			return false
+		}
+		v, ok := assertAs[V](value)
+		if !ok {
+			err = fmt.Errorf("swisstable: value %v of key %v is %T, not %v", value, key, value, reflect.TypeFor[V]())
+			return false
+		}
+		t.Put(k, v)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// FromGeneric copies a Table[K, V] into a new any-keyed SwissTable sized to
+// hold it
+func FromGeneric[K comparable, V any](t *Table[K, V]) *SwissTable {
+	st := NewWithCapacity(t.size)
+	keys, values := t.Entries()
+	for i, k := range keys {
+		st.Put(k, values[i])
+	}
+	return st
+}
+
+// assertAs is x.(T) that also accepts a nil x when T is an interface type
+func assertAs[T any](x any) (T, bool) {
+	if x == nil {
+		var zero T
+		return zero, reflect.TypeFor[T]().Kind() == reflect.Interface
+	}
+	v, ok := x.(T)
+	return v, ok
+}
@@ -0,0 +1,200 @@
+package swisstable
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	tbl := NewTable[string, int]()
+	tbl.Put("one", 1)
+	if v, ok := tbl.Get("one"); !ok || v != 1 {
+		t.Errorf("Expected (1, true), got (%v, %v)", v, ok)
+	}
+	tbl.Put("one", 11)
+	if v, ok := tbl.Get("one"); !ok || v != 11 {
+		t.Errorf("Expected (11, true), got (%v, %v)", v, ok)
+	}
+	if v, ok := tbl.Get("missing"); ok || v != 0 {
+		t.Errorf("Expected (0, false) for a miss, got (%v, %v)", v, ok)
+	}
+	if !tbl.Delete("one") || tbl.Delete("one") {
+		t.Error("Expected Delete to succeed exactly once")
+	}
+	if tbl.Size() != 0 {
+		t.Errorf("Expected size 0, got %d", tbl.Size())
+	}
+}
+
+func TestTableVsMap(t *testing.T) {
+	tbl := NewTable[int, int]()
+	ref := make(map[int]int)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		k := rng.Intn(2000)
+		switch rng.Intn(3) {
+		case 0, 1:
+			tbl.Put(k, i)
+			ref[k] = i
+		case 2:
+			_, want := ref[k]
+			if got := tbl.Delete(k); got != want {
+				t.Fatalf("Op %d: Delete(%d) returned %v, expected %v", i, k, got, want)
+			}
+			delete(ref, k)
+		}
+	}
+
+	if tbl.Size() != len(ref) {
+		t.Errorf("Expected size %d, got %d", len(ref), tbl.Size())
+	}
+	for k := 0; k < 2000; k++ {
+		want, wantOK := ref[k]
+		if got, ok := tbl.Get(k); got != want || ok != wantOK {
+			t.Errorf("Key %d: expected (%d, %v), got (%d, %v)", k, want, wantOK, got, ok)
+		}
+	}
+}
+
+func TestTableChurn(t *testing.T) {
+	tbl := NewTable[int, int]()
+	for i := 0; i < 1000; i++ {
+		tbl.Put(i, i)
+	}
+	for i := 1000; i < 200000; i++ {
+		tbl.Put(i, i)
+		tbl.Delete(i - 1000)
+	}
+
+	empty, tombstones := 0, 0
+	for i := range tbl.metadata {
+		empty += bits.OnesCount16(matchEmpty(&tbl.metadata[i]))
+		tombstones += bits.OnesCount16(matchGroup(&tbl.metadata[i], ctrlDeleted))
+	}
+	if tombstones != tbl.tombstones {
+		t.Errorf("Expected a tombstone count of %d, got %d", tombstones, tbl.tombstones)
+	}
+	if empty < len(tbl.entries)/4 {
+		t.Errorf("Expected at least %d empty slots after churn, got %d", len(tbl.entries)/4, empty)
+	}
+	if len(tbl.entries) > 4096 {
+		t.Errorf("Expected churn not to keep growing the table, got %d slots", len(tbl.entries))
+	}
+	for i := 199000; i < 200000; i++ {
+		if v, ok := tbl.Get(i); !ok || v != i {
+			t.Errorf("Key %d: expected (%d, true), got (%v, %v)", i, i, v, ok)
+		}
+	}
+	if tbl.Size() != 1000 {
+		t.Errorf("Expected size 1000, got %d", tbl.Size())
+	}
+}
+
+func TestTableEntries(t *testing.T) {
+	if keys, values := NewTable[int, string]().Entries(); keys == nil || values == nil || len(keys) != 0 || len(values) != 0 {
+		t.Errorf("Expected non-nil empty slices, got %v and %v", keys, values)
+	}
+
+	tbl := NewTable[int, string]()
+	for i := 0; i < 100; i++ {
+		tbl.Put(i, fmt.Sprint("v", i))
+	}
+	tbl.Delete(42)
+
+	keys, values := tbl.Entries()
+	if len(keys) != tbl.Size() || len(values) != tbl.Size() {
+		t.Fatalf("Expected %d keys and values, got %d and %d", tbl.Size(), len(keys), len(values))
+	}
+	for i, k := range keys {
+		if want, _ := tbl.Get(k); values[i] != want {
+			t.Errorf("Index %d: value %q does not belong to key %d", i, values[i], k)
+		}
+		if k == 42 {
+			t.Error("Expected the deleted key to be absent")
+		}
+	}
+}
+
+func TestToGeneric(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, fmt.Sprint("v", i))
+	}
+
+	tbl, err := ToGeneric[int, string](st)
+	if err != nil {
+		t.Fatalf("ToGeneric failed: %v", err)
+	}
+	if tbl.Size() != st.Size() {
+		t.Errorf("Expected size %d, got %d", st.Size(), tbl.Size())
+	}
+	for i := 0; i < 100; i++ {
+		if v, ok := tbl.Get(i); !ok || v != fmt.Sprint("v", i) {
+			t.Errorf("Key %d: expected (v%d, true), got (%q, %v)", i, i, v, ok)
+		}
+	}
+
+	back := FromGeneric(tbl)
+	if back.Size() != st.Size() {
+		t.Errorf("Expected size %d after the round trip, got %d", st.Size(), back.Size())
+	}
+	st.Range(func(key, value any) bool {
+		if got, ok := back.Get(key); !ok || got != value {
+			t.Errorf("Key %v: expected (%v, true) after the round trip, got (%v, %v)", key, value, got, ok)
+		}
+		return true
+	})
+	checkInvariants(t, back)
+
+	// Mismatched keys and values are rejected
+	st.Put("ten", "v10")
+	if _, err := ToGeneric[int, string](st); err == nil {
+		t.Error("Expected an error for a string key")
+	}
+	st.Delete("ten")
+	st.Put(10, 10)
+	if _, err := ToGeneric[int, string](st); err == nil {
+		t.Error("Expected an error for an int value")
+	}
+	st.Put(10, nil)
+	if _, err := ToGeneric[int, string](st); err == nil {
+		t.Error("Expected an error for a nil string value")
+	}
+	if tbl, err := ToGeneric[int, any](st); err != nil || tbl.Size() != st.Size() {
+		t.Errorf("Expected nil to convert to an interface value, got %v", err)
+	}
+}
+
+func BenchmarkTable(b *testing.B) {
+	keys := make([]int, 1000)
+	for i := range keys {
+		keys[i] = i * 7919
+	}
+
+	b.Run("any", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			st := New()
+			for _, k := range keys {
+				st.Put(k, k)
+			}
+			for _, k := range keys {
+				st.Get(k)
+			}
+		}
+	})
+	b.Run("generic", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			tbl := NewTable[int, int]()
+			for _, k := range keys {
+				tbl.Put(k, k)
+			}
+			for _, k := range keys {
+				tbl.Get(k)
+			}
+		}
+	})
+}
@@ -1,3 +1,3 @@
 module swisstable
 
-go 1.22
+go 1.24
@@ -0,0 +1,59 @@
+package swisstable
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+)
+
+// gobSnapshot is the wire form of a SwissTable, the live entries in slot order
+type gobSnapshot struct {
+	Size   int
+	Keys   []any
+	Values []any
+}
+
+// GobEncode encodes the live entries and the size, ignoring the slot layout
+// and settings. It implements gob.GobEncoder. Keys and values travel as
+// interfaces, so every concrete type other than gob's predeclared basic
+// types (bool, numbers, string, []byte and slices of those) must be passed
+// to gob.Register on both ends, including types nested in a value.
+func (st *SwissTable) GobEncode() ([]byte, error) {
+	snap := gobSnapshot{
+		Size:   st.size,
+		Keys:   st.Keys(),
+		Values: st.Values(),
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode replaces the table's contents with entries written by GobEncode,
+// inserting each through Put into a fresh backing array sized for them. The
+// table keeps its settings. It implements gob.GobDecoder and also works on a
+// zero SwissTable.
+func (st *SwissTable) GobDecode(data []byte) error {
+	var snap gobSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return err
+	}
+	if len(snap.Keys) != snap.Size || len(snap.Values) != snap.Size {
+		return errors.New("swisstable: gob snapshot size does not match its entries")
+	}
+
+	if len(st.entries) == 0 {
+		*st = *New()
+	}
+	st.Clear()
+	// A fresh array sized for the snapshot, a frozen capacity keeps the old one
+	_ = st.resizeTo(st.capacityFor(snap.Size))
+	for i, key := range snap.Keys {
+		if err := st.PutErr(key, snap.Values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
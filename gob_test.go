@@ -0,0 +1,66 @@
+package swisstable
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+// gobPoint is a custom value type, which gob needs registered to send as any
+type gobPoint struct{ X, Y int }
+
+func TestGobRoundTrip(t *testing.T) {
+	gob.Register(gobPoint{})
+
+	st := New()
+	for i := 0; i < 200; i++ {
+		st.Put(i, i*i)
+	}
+	for i := 0; i < 200; i += 3 {
+		st.Delete(i)
+	}
+	st.Put("origin", gobPoint{0, 0})
+	st.Put("name", "swiss")
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(st); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	restored := New()
+	restored.Put("stale", true)
+	if err := gob.NewDecoder(&buf).Decode(restored); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if restored.Size() != st.Size() || restored.Contains("stale") {
+		t.Errorf("Expected exactly the %d encoded entries, got %d", st.Size(), restored.Size())
+	}
+	st.Range(func(key, value any) bool {
+		if v, ok := restored.Get(key); !ok || v != value {
+			t.Errorf("Key %v: expected (%v, true), got (%v, %v)", key, value, v, ok)
+		}
+		return true
+	})
+	if want := restored.capacityFor(st.Size()); restored.Cap() != want {
+		t.Errorf("Expected a backing array of %d slots, got %d", want, restored.Cap())
+	}
+	checkInvariants(t, restored)
+
+	// Decoding into a zero table works too
+	data, _ := st.GobEncode()
+	var zero SwissTable
+	if err := zero.GobDecode(data); err != nil || zero.Size() != st.Size() {
+		t.Errorf("Expected a zero table to decode %d entries, got %d (%v)", st.Size(), zero.Size(), err)
+	}
+}
+
+func TestGobDecodeRejects(t *testing.T) {
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(gobSnapshot{Size: 2, Keys: []any{1}, Values: []any{1}})
+	if err := New().GobDecode(buf.Bytes()); err == nil {
+		t.Error("Expected an error for a size that does not match the entries")
+	}
+	if err := New().GobDecode([]byte("not gob")); err == nil {
+		t.Error("Expected an error for malformed input")
+	}
+}
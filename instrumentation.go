@@ -0,0 +1,136 @@
+package swisstable
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+	"time"
+)
+
+const (
+	// Number of power-of-two latency buckets, enough to cover durations past one second
+	latencyBuckets = 32
+	// Number of synthetic keys used by PerformanceReport
+	reportKeys = 5000
+)
+
+// Histogram accumulates operation durations in power-of-two buckets. Bucket i
+// counts durations in [2^i, 2^(i+1)) nanoseconds, with sub-nanosecond samples
+// in bucket 0 and anything slower in the last bucket.
+type Histogram struct {
+	Count   uint64
+	Total   time.Duration
+	Buckets [latencyBuckets]uint64
+}
+
+// Mean returns the average recorded duration
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Total / time.Duration(h.Count)
+}
+
+// observe adds one sample to the histogram
+func (h *Histogram) observe(d time.Duration) {
+	bucket := 0
+	if d > 0 {
+		bucket = min(bits.Len64(uint64(d))-1, latencyBuckets-1)
+	}
+	h.Buckets[bucket]++
+	h.Count++
+	h.Total += d
+}
+
+// Latencies holds one histogram per public operation
+type Latencies struct {
+	Put    Histogram
+	Get    Histogram
+	Delete Histogram
+}
+
+// EnableInstrumentation starts timing every Put, Get, Delete and resize and
+// counting the bytes fed to the hasher. Timing costs two clock reads per
+// operation, so it is off by default and the untimed path only pays for a
+// flag check.
+func (st *SwissTable) EnableInstrumentation() {
+	st.instrumented = true
+}
+
+// LatencyHistogram returns a copy of the latencies recorded since
+// EnableInstrumentation
+func (st *SwissTable) LatencyHistogram() Latencies {
+	return st.latencies
+}
+
+// BytesHashed returns the number of key bytes fed to the default hasher since
+// EnableInstrumentation, including rehashing during resizes. Keys hashed by a
+// custom hasher are not counted since their size is unknown.
+func (st *SwissTable) BytesHashed() uint64 {
+	return st.bytesHashed
+}
+
+// TotalResizeTime returns the time spent resizing since
+// EnableInstrumentation, including resizes that failed and were rolled back
+func (st *SwissTable) TotalResizeTime() time.Duration {
+	return st.resizeTime
+}
+
+// MaxKeyComparisons returns the most full key compares any single lookup has
+// made since EnableInstrumentation, a measure of fingerprint collisions
+func (st *SwissTable) MaxKeyComparisons() int {
+	return st.maxCompared
+}
+
+// observeSince records the time elapsed since start into h
+func observeSince(h *Histogram, start time.Time) {
+	h.observe(time.Since(start))
+}
+
+// PerformanceReport runs a short self-benchmark on a synthetic integer key
+// set and summarizes the time per operation and the resulting probe lengths.
+// The numbers come from a single unwarmed run and are only a rough sanity
+// check of this machine, not a replacement for go test -bench.
+func PerformanceReport() string {
+	st := New()
+
+	start := time.Now()
+	for i := 0; i < reportKeys; i++ {
+		st.Put(i, i)
+	}
+	insert := time.Since(start)
+
+	start = time.Now()
+	for i := 0; i < reportKeys; i++ {
+		st.Get(i)
+	}
+	hit := time.Since(start)
+
+	start = time.Now()
+	for i := reportKeys; i < 2*reportKeys; i++ {
+		st.Get(i)
+	}
+	miss := time.Since(start)
+
+	avgProbe, maxProbe := st.probeStats()
+	perOp := func(d time.Duration) time.Duration { return d / reportKeys }
+
+	var result strings.Builder
+	result.WriteString("Swiss Table Performance Report (approximate)\n")
+	result.WriteString(strings.Repeat("=", 50) + "\n")
+	fmt.Fprintf(&result, "Keys: %d sequential ints\n", reportKeys)
+	result.WriteString(strings.Repeat("-", 50) + "\n\n")
+
+	result.WriteString("Timing (ns/op):\n")
+	fmt.Fprintf(&result, "  Insert:        %8d\n", perOp(insert).Nanoseconds())
+	fmt.Fprintf(&result, "  Lookup (hit):  %8d\n", perOp(hit).Nanoseconds())
+	fmt.Fprintf(&result, "  Lookup (miss): %8d\n", perOp(miss).Nanoseconds())
+
+	result.WriteString("\nProbe Stats:\n")
+	fmt.Fprintf(&result, "  Capacity:      %8d\n", len(st.entries))
+	fmt.Fprintf(&result, "  Load factor:   %8.2f\n", float64(st.size)/float64(len(st.entries)))
+	fmt.Fprintf(&result, "  Avg probe:     %8.2f\n", avgProbe)
+	fmt.Fprintf(&result, "  Max probe:     %8d\n", maxProbe)
+
+	return result.String()
+}
@@ -0,0 +1,144 @@
+package swisstable
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	st := New()
+	st.Put("untimed", 0)
+	if h := st.LatencyHistogram(); h.Put.Count != 0 {
+		t.Errorf("Expected no samples before enabling, got %d", h.Put.Count)
+	}
+
+	st.EnableInstrumentation()
+	for i := 0; i < 50; i++ {
+		st.Put(i, i)
+	}
+	for i := 0; i < 30; i++ {
+		st.Get(i)
+	}
+	for i := 0; i < 10; i++ {
+		st.Delete(i)
+	}
+
+	h := st.LatencyHistogram()
+	for _, tc := range []struct {
+		name string
+		hist Histogram
+		want uint64
+	}{
+		{"Put", h.Put, 50},
+		{"Get", h.Get, 30},
+		{"Delete", h.Delete, 10},
+	} {
+		if tc.hist.Count != tc.want {
+			t.Errorf("%s: expected %d samples, got %d", tc.name, tc.want, tc.hist.Count)
+		}
+		var inBuckets uint64
+		for _, n := range tc.hist.Buckets {
+			inBuckets += n
+		}
+		if inBuckets != tc.hist.Count {
+			t.Errorf("%s: buckets hold %d samples, count is %d", tc.name, inBuckets, tc.hist.Count)
+		}
+		if tc.hist.Total <= 0 || tc.hist.Mean() <= 0 {
+			t.Errorf("%s: expected positive total and mean, got %v and %v", tc.name, tc.hist.Total, tc.hist.Mean())
+		}
+	}
+}
+
+func TestBytesHashed(t *testing.T) {
+	st := New()
+	st.Put("before", 0)
+	st.EnableInstrumentation()
+	if got := st.BytesHashed(); got != 0 {
+		t.Errorf("Expected no bytes counted before enabling, got %d", got)
+	}
+
+	// Each operation hashes its key once; neither key triggers a resize
+	st.Put("hello", 1)
+	st.Get("hello")
+	st.Delete("hello")
+	st.Put(12345, 2)
+
+	// A tag byte precedes each key: "hello" by its bytes, 12345 as a 64-bit word
+	if got, want := st.BytesHashed(), uint64(3*(1+len("hello"))+1+8); got != want {
+		t.Errorf("Expected %d bytes hashed, got %d", want, got)
+	}
+}
+
+func TestTotalResizeTime(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i)
+	}
+	if got := st.TotalResizeTime(); got != 0 {
+		t.Errorf("Expected no resize time before enabling, got %v", got)
+	}
+
+	st.EnableInstrumentation()
+	groups := st.groupCount
+	for i := 100; i < 2000; i++ {
+		st.Put(i, i)
+	}
+	if st.groupCount < groups*4 {
+		t.Fatalf("Expected several resizes, groups only grew from %d to %d", groups, st.groupCount)
+	}
+	if got := st.TotalResizeTime(); got <= 0 {
+		t.Errorf("Expected accumulated resize time, got %v", got)
+	}
+}
+
+func TestMaxKeyComparisons(t *testing.T) {
+	st := New()
+	for i := 0; i < 10; i++ {
+		st.Put(collidingKey(i), i)
+	}
+	st.EnableInstrumentation()
+	st.Get(collidingKey(0))
+	if got := st.MaxKeyComparisons(); got != 1 {
+		t.Errorf("Expected 1 compare for the first colliding key, got %d", got)
+	}
+	st.Get(collidingKey(10))
+	if got := st.MaxKeyComparisons(); got != 10 {
+		t.Errorf("Expected a miss to compare against all 10 colliding keys, got %d", got)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	var h Histogram
+	h.observe(0)
+	h.observe(1)
+	h.observe(1500 * time.Nanosecond)
+	h.observe(time.Hour)
+
+	if h.Buckets[0] != 2 {
+		t.Errorf("Expected 2 samples in bucket 0, got %d", h.Buckets[0])
+	}
+	if h.Buckets[10] != 1 {
+		t.Errorf("Expected 1500ns in bucket 10, got %v", h.Buckets)
+	}
+	if h.Buckets[latencyBuckets-1] != 1 {
+		t.Errorf("Expected an hour to clamp into the last bucket, got %v", h.Buckets)
+	}
+}
+
+func TestPerformanceReport(t *testing.T) {
+	report := PerformanceReport()
+	for _, section := range []string{
+		"(approximate)",
+		"Timing (ns/op):",
+		"Insert:",
+		"Lookup (hit):",
+		"Lookup (miss):",
+		"Probe Stats:",
+		"Max probe:",
+	} {
+		if !strings.Contains(report, section) {
+			t.Errorf("Report is missing %q:\n%s", section, report)
+		}
+	}
+}
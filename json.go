@@ -0,0 +1,125 @@
+package swisstable
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var errInvalidJSON = errors.New("swisstable: JSON must be an object or an array of [key, value] pairs")
+
+// MarshalJSON encodes the live entries in slot order: as an object when every
+// key is a string, otherwise as an array of [key, value] pairs. It
+// implements json.Marshaler.
+func (st *SwissTable) MarshalJSON() ([]byte, error) {
+	allStrings := true
+	st.Range(func(key, value any) bool {
+		_, allStrings = key.(string)
+		return allStrings
+	})
+
+	if !allStrings {
+		pairs := make([][2]any, 0, st.size)
+		st.Range(func(key, value any) bool {
+			pairs = append(pairs, [2]any{key, value})
+			return true
+		})
+		return json.Marshal(pairs)
+	}
+
+	// Build the object by hand to keep slot order, map encoding sorts keys
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	var err error
+	first := true
+	st.Range(func(key, value any) bool {
+		var k, v []byte
+		if k, err = json.Marshal(key); err != nil {
+			return false
+		}
+		if v, err = json.Marshal(value); err != nil {
+			return false
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON replaces the table's contents with the entries of an object
+// or array of pairs written by MarshalJSON, inserting each through Put. The
+// slot layout is not preserved. JSON numbers become int when integral and
+// float64 otherwise, and objects and arrays become map[string]any and []any.
+// It implements json.Unmarshaler and also works on a zero SwissTable.
+func (st *SwissTable) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw any
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	var pairs [][2]any
+	switch doc := raw.(type) {
+	case map[string]any:
+		for k, v := range doc {
+			pairs = append(pairs, [2]any{k, fromJSON(v)})
+		}
+	case []any:
+		for _, p := range doc {
+			pair, ok := p.([]any)
+			if !ok || len(pair) != 2 {
+				return errInvalidJSON
+			}
+			pairs = append(pairs, [2]any{fromJSON(pair[0]), fromJSON(pair[1])})
+		}
+	default:
+		return errInvalidJSON
+	}
+
+	if len(st.entries) == 0 {
+		*st = *New()
+	}
+	st.Clear()
+	for _, p := range pairs {
+		if err := st.PutErr(p[0], p[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fromJSON converts decoded JSON numbers to int when integral and to float64
+// otherwise, recursing into arrays and objects
+func fromJSON(v any) any {
+	switch x := v.(type) {
+	case json.Number:
+		if !strings.ContainsAny(x.String(), ".eE") {
+			if n, err := x.Int64(); err == nil && int64(int(n)) == n {
+				return int(n)
+			}
+		}
+		f, _ := x.Float64()
+		return f
+	case []any:
+		for i := range x {
+			x[i] = fromJSON(x[i])
+		}
+	case map[string]any:
+		for k := range x {
+			x[k] = fromJSON(x[k])
+		}
+	}
+	return v
+}
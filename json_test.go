@@ -0,0 +1,79 @@
+package swisstable
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	for name, pairs := range map[string][][2]any{
+		"string keys": {{"one", 1}, {"pi", 3.5}, {"yes", true}, {"none", nil}, {"name", "swiss"}},
+		"mixed keys":  {{1, "one"}, {"two", 2}, {3.5, false}, {-4, -4.25}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			st := New()
+			for _, p := range pairs {
+				st.Put(p[0], p[1])
+			}
+			data, err := json.Marshal(st)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			var restored SwissTable
+			if err := json.Unmarshal(data, &restored); err != nil {
+				t.Fatalf("Unmarshal of %s failed: %v", data, err)
+			}
+			if restored.Size() != len(pairs) {
+				t.Errorf("Expected %d entries from %s, got %d", len(pairs), data, restored.Size())
+			}
+			for _, p := range pairs {
+				if v, ok := restored.Get(p[0]); !ok || v != p[1] {
+					t.Errorf("Key %v: expected (%v, true), got (%v, %v)", p[0], p[1], v, ok)
+				}
+			}
+			checkInvariants(t, &restored)
+		})
+	}
+}
+
+func TestMarshalJSONShape(t *testing.T) {
+	st := New()
+	st.Put("a", 1)
+	data, _ := st.MarshalJSON()
+	if string(data) != `{"a":1}` {
+		t.Errorf(`Expected {"a":1}, got %s`, data)
+	}
+
+	st.Put(2, "b")
+	data, _ = st.MarshalJSON()
+	var pairs [][2]any
+	if err := json.Unmarshal(data, &pairs); err != nil || len(pairs) != 2 {
+		t.Errorf("Expected an array of 2 pairs for a non-string key, got %s", data)
+	}
+
+	if data, _ := New().MarshalJSON(); string(data) != "{}" {
+		t.Errorf("Expected {} for an empty table, got %s", data)
+	}
+}
+
+func TestUnmarshalJSONReplaces(t *testing.T) {
+	st := New()
+	st.Put("stale", 1)
+	if err := st.UnmarshalJSON([]byte(`[[1, {"nested": [2, 2.5]}]]`)); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %v", err)
+	}
+	if st.Contains("stale") || st.Size() != 1 {
+		t.Errorf("Expected the old contents to be replaced, got size %d", st.Size())
+	}
+	v, _ := st.Get(1)
+	if nested := v.(map[string]any)["nested"].([]any); nested[0] != 2 || nested[1] != 2.5 {
+		t.Errorf("Expected nested numbers [2 2.5] as int and float64, got %#v", nested)
+	}
+
+	for _, bad := range []string{`"string"`, `[[1, 2, 3]]`, `[1]`, `{`} {
+		if err := New().UnmarshalJSON([]byte(bad)); err == nil {
+			t.Errorf("Expected an error for %s", bad)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package swisstable
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Layout checkpoint, all integers little-endian:
+//
+//	offset 0   magic "SWTL"
+//	offset 4   version (uint32)
+//	offset 8   slot count (uint64, a multiple of groupSize)
+//	offset 16  live entry count (uint64)
+//	offset 24  generation (uint64)
+//	offset 32  partitions (uint64)
+//	offset 40  control bytes, one per slot
+//	then       entries, layoutEntrySize bytes per slot: key, value and
+//	           metadata as tagged scalars, then H2 (1), generation (8) and
+//	           fingerprint (4)
+//
+// A tagged scalar is a one-byte type tag followed by 8 bytes of payload.
+const (
+	layoutMagic      = "SWTL"
+	layoutVersion    = 1
+	layoutHeaderSize = 40
+	layoutScalarSize = 9
+	layoutEntrySize  = 3*layoutScalarSize + 1 + 8 + 4
+)
+
+// Type tags of a tagged scalar
+const (
+	layoutNil = iota
+	layoutInt
+	layoutInt8
+	layoutInt16
+	layoutInt32
+	layoutInt64
+	layoutUint
+	layoutUint8
+	layoutUint16
+	layoutUint32
+	layoutUint64
+	layoutFloat32
+	layoutFloat64
+	layoutBool
+)
+
+var errLayout = errors.New("swisstable: invalid layout checkpoint")
+
+// MarshalLayout encodes the table's internal state verbatim: every slot's
+// control byte and entry, the size and the generation. Keys, values and
+// metadata must be nil, bool or fixed-size numbers. A maphash seed cannot be
+// exported, so the table must use a custom hasher (see Builder.WithHasher).
+func (st *SwissTable) MarshalLayout() ([]byte, error) {
+	if st.hash == nil {
+		return nil, errors.New("swisstable: layout checkpoints need a custom hasher")
+	}
+
+	slots := len(st.entries)
+	data := make([]byte, layoutHeaderSize+slots+slots*layoutEntrySize)
+	copy(data, layoutMagic)
+	binary.LittleEndian.PutUint32(data[4:], layoutVersion)
+	binary.LittleEndian.PutUint64(data[8:], uint64(slots))
+	binary.LittleEndian.PutUint64(data[16:], uint64(st.size))
+	binary.LittleEndian.PutUint64(data[24:], st.generation)
+	binary.LittleEndian.PutUint64(data[32:], uint64(st.partitions))
+
+	ctrl := data[layoutHeaderSize : layoutHeaderSize+slots]
+	for i, group := range st.metadata {
+		copy(ctrl[i*groupSize:], group.bytes[:])
+	}
+
+	raw := data[layoutHeaderSize+slots:]
+	for idx, e := range st.entries {
+		rec := raw[idx*layoutEntrySize : (idx+1)*layoutEntrySize]
+		for i, v := range []any{e.key, e.value, e.meta} {
+			if err := putLayoutScalar(rec[i*layoutScalarSize:], v); err != nil {
+				return nil, fmt.Errorf("swisstable: slot %d: %w", idx, err)
+			}
+		}
+		rec[3*layoutScalarSize] = e.h2Hash
+		binary.LittleEndian.PutUint64(rec[3*layoutScalarSize+1:], e.gen)
+		binary.LittleEndian.PutUint32(rec[3*layoutScalarSize+9:], e.fp)
+	}
+	return data, nil
+}
+
+// UnmarshalLayout replaces the table's contents with a checkpoint written by
+// MarshalLayout, without rehashing. The table must be configured with the
+// same hasher and equality as the one that was checkpointed; the load factor
+// and other settings are kept. The receiver's soft-deleted entries are
+// dropped, since the checkpoint does not hold them, and a table created with
+// NewDedupValues rebuilds its shared values from the restored entries.
+func (st *SwissTable) UnmarshalLayout(data []byte) error {
+	st.checkMutable()
+	if st.hash == nil {
+		return errors.New("swisstable: layout checkpoints need a custom hasher")
+	}
+	if len(data) < layoutHeaderSize || string(data[:4]) != layoutMagic {
+		return errLayout
+	}
+	if binary.LittleEndian.Uint32(data[4:]) != layoutVersion {
+		return errors.New("swisstable: unsupported layout checkpoint version")
+	}
+	slots64 := binary.LittleEndian.Uint64(data[8:])
+	size64 := binary.LittleEndian.Uint64(data[16:])
+	partitions64 := binary.LittleEndian.Uint64(data[32:])
+	if slots64 == 0 || slots64%groupSize != 0 || size64 > slots64 ||
+		slots64 > uint64(len(data)) || uint64(len(data)) != layoutHeaderSize+slots64*(1+layoutEntrySize) ||
+		(partitions64 > 1 && slots64/groupSize%partitions64 != 0) {
+		return errLayout
+	}
+	slots := int(slots64)
+
+	ctrl := data[layoutHeaderSize : layoutHeaderSize+slots]
+	raw := data[layoutHeaderSize+slots:]
+	metadata := make([]metadata, slots/groupSize)
+	entries := make([]entry, slots)
+	live := 0
+	for idx := range entries {
+		rec := raw[idx*layoutEntrySize : (idx+1)*layoutEntrySize]
+		var fields [3]any
+		for i := range fields {
+			v, err := layoutScalar(rec[i*layoutScalarSize:])
+			if err != nil {
+				return err
+			}
+			fields[i] = v
+		}
+		e := entry{
+			key:    fields[0],
+			value:  fields[1],
+			meta:   fields[2],
+			h2Hash: rec[3*layoutScalarSize],
+			gen:    binary.LittleEndian.Uint64(rec[3*layoutScalarSize+1:]),
+			fp:     binary.LittleEndian.Uint32(rec[3*layoutScalarSize+9:]),
+		}
+
+		c := ctrl[idx]
+		switch {
+		case isFull(c):
+			if e.key == nil || e.h2Hash != c {
+				return errLayout
+			}
+			live++
+		case c == ctrlEmpty || c == ctrlDeleted:
+			if e.key != nil {
+				return errLayout
+			}
+		default:
+			return errLayout
+		}
+		metadata[idx/groupSize].bytes[idx%groupSize] = c
+		entries[idx] = e
+	}
+	if live != int(size64) {
+		return errLayout
+	}
+
+	st.softDeleted = nil
+	if st.dedup != nil {
+		clear(st.dedup.values)
+		for idx := range entries {
+			if isFull(ctrl[idx]) {
+				entries[idx].value = st.dedup.acquire(entries[idx].value)
+			}
+		}
+	}
+	st.entries = entries
+	st.metadata = metadata
+	st.recountOccupancy()
+	st.groupCount = len(metadata)
+	st.size = live
+	st.generation = binary.LittleEndian.Uint64(data[24:])
+	st.partitions = int(partitions64)
+	st.probeSum = 0
+	st.probeCount = 0
+	st.epoch++
+	return nil
+}
+
+// putLayoutScalar writes v as a tagged scalar into b
+func putLayoutScalar(b []byte, v any) error {
+	var tag byte
+	var bits uint64
+	switch x := v.(type) {
+	case nil:
+		tag = layoutNil
+	case int:
+		tag, bits = layoutInt, uint64(x)
+	case int8:
+		tag, bits = layoutInt8, uint64(x)
+	case int16:
+		tag, bits = layoutInt16, uint64(x)
+	case int32:
+		tag, bits = layoutInt32, uint64(x)
+	case int64:
+		tag, bits = layoutInt64, uint64(x)
+	case uint:
+		tag, bits = layoutUint, uint64(x)
+	case uint8:
+		tag, bits = layoutUint8, uint64(x)
+	case uint16:
+		tag, bits = layoutUint16, uint64(x)
+	case uint32:
+		tag, bits = layoutUint32, uint64(x)
+	case uint64:
+		tag, bits = layoutUint64, x
+	case float32:
+		tag, bits = layoutFloat32, uint64(math.Float32bits(x))
+	case float64:
+		tag, bits = layoutFloat64, math.Float64bits(x)
+	case bool:
+		tag = layoutBool
+		if x {
+			bits = 1
+		}
+	default:
+		return fmt.Errorf("type %T is not fixed-size", v)
+	}
+	b[0] = tag
+	binary.LittleEndian.PutUint64(b[1:], bits)
+	return nil
+}
+
+// layoutScalar decodes a tagged scalar written by putLayoutScalar
+func layoutScalar(b []byte) (any, error) {
+	bits := binary.LittleEndian.Uint64(b[1:])
+	switch b[0] {
+	case layoutNil:
+		return nil, nil
+	case layoutInt:
+		return int(bits), nil
+	case layoutInt8:
+		return int8(bits), nil
+	case layoutInt16:
+		return int16(bits), nil
+	case layoutInt32:
+		return int32(bits), nil
+	case layoutInt64:
+		return int64(bits), nil
+	case layoutUint:
+		return uint(bits), nil
+	case layoutUint8:
+		return uint8(bits), nil
+	case layoutUint16:
+		return uint16(bits), nil
+	case layoutUint32:
+		return uint32(bits), nil
+	case layoutUint64:
+		return bits, nil
+	case layoutFloat32:
+		return math.Float32frombits(uint32(bits)), nil
+	case layoutFloat64:
+		return math.Float64frombits(bits), nil
+	case layoutBool:
+		return bits != 0, nil
+	}
+	return nil, errLayout
+}
@@ -0,0 +1,109 @@
+package swisstable
+
+import (
+	"errors"
+	"testing"
+)
+
+// layoutHasher is a deterministic hasher for integer keys, usable across
+// tables as layout checkpoints require
+func layoutHasher(key any) uint64 {
+	h, _ := preDistributedHash(key)
+	return h
+}
+
+func TestMarshalLayout(t *testing.T) {
+	st, err := NewBuilder().WithHasher(layoutHasher).Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		st.Put(i, float64(i)/2)
+	}
+	for i := 0; i < 200; i += 7 {
+		st.Delete(i)
+	}
+	st.PutMeta(1000, true, uint8(3))
+
+	data, err := st.MarshalLayout()
+	if err != nil {
+		t.Fatalf("MarshalLayout failed: %v", err)
+	}
+
+	restored, _ := NewBuilder().WithHasher(layoutHasher).Build()
+	if err := restored.UnmarshalLayout(data); err != nil {
+		t.Fatalf("UnmarshalLayout failed: %v", err)
+	}
+	if got, want := restored.Visualize(), st.Visualize(); got != want {
+		t.Errorf("Restored layout differs:\n%s\nexpected:\n%s", got, want)
+	}
+	if restored.ExportMetadata()[0] != st.ExportMetadata()[0] || restored.Generation() != st.Generation() {
+		t.Error("Expected control bytes and generation to be restored verbatim")
+	}
+	if _, meta, ok := restored.GetMeta(1000); !ok || meta != uint8(3) {
+		t.Errorf("Expected metadata 3 for key 1000, got (%v, %v)", meta, ok)
+	}
+	checkInvariants(t, restored)
+}
+
+func TestMarshalLayoutRejects(t *testing.T) {
+	if _, err := New().MarshalLayout(); err == nil {
+		t.Error("Expected an error for a table using maphash")
+	}
+
+	st, _ := NewBuilder().WithHasher(layoutHasher).Build()
+	st.Put(1, "not fixed-size")
+	if _, err := st.MarshalLayout(); err == nil {
+		t.Error("Expected an error for a string value")
+	}
+
+	st.Put(1, 2)
+	data, _ := st.MarshalLayout()
+	for name, corrupt := range map[string]func([]byte) []byte{
+		"magic":     func(b []byte) []byte { b[0] = 'X'; return b },
+		"truncated": func(b []byte) []byte { b[8] = 0xFF; return b },
+		"size":      func(b []byte) []byte { b[16] = 3; return b },
+		"trailing":  func(b []byte) []byte { return append(b, make([]byte, layoutEntrySize)...) },
+		"control": func(b []byte) []byte {
+			for i := layoutHeaderSize; ; i++ {
+				if b[i] == ctrlEmpty {
+					b[i] = ctrlSentinel
+					return b
+				}
+			}
+		},
+	} {
+		bad := corrupt(append([]byte(nil), data...))
+		restored, _ := NewBuilder().WithHasher(layoutHasher).Build()
+		if err := restored.UnmarshalLayout(bad); !errors.Is(err, errLayout) {
+			t.Errorf("%s: expected errLayout, got %v", name, err)
+		}
+	}
+}
+
+func TestUnmarshalLayoutResetsSideTables(t *testing.T) {
+	st, _ := NewBuilder().WithHasher(layoutHasher).Build()
+	st.Put(1, 10)
+	data, _ := st.MarshalLayout()
+
+	restored := NewDedupValues(layoutHasher)
+	restored.hash = layoutHasher
+	restored.Put(2, 20)
+	restored.Put(3, 30)
+	restored.SoftDelete(2)
+	if err := restored.UnmarshalLayout(data); err != nil {
+		t.Fatalf("UnmarshalLayout failed: %v", err)
+	}
+
+	if _, _, ok := restored.GetDeleted(2); ok {
+		t.Error("Expected soft-deleted entries missing from the checkpoint to be dropped")
+	}
+	if n := restored.dedup.unique(); n != 1 || restored.dedup.values[layoutHasher(10)] == nil {
+		t.Errorf("Expected only the restored value 10 to be shared, got %d values", n)
+	}
+	restored.Delete(1)
+	if n := restored.dedup.unique(); n != 0 {
+		t.Errorf("Expected the shared value to be released with its only entry, got %d left", n)
+	}
+	checkInvariants(t, restored)
+}
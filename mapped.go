@@ -0,0 +1,222 @@
+package swisstable
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"unsafe"
+)
+
+// Mapped layout, all integers little-endian:
+//
+//	offset 0   magic "SWTM"
+//	offset 4   version (uint32)
+//	offset 8   slot count (uint64, a multiple of groupSize)
+//	offset 16  live entry count (uint64)
+//	offset 24  control bytes, one per slot, in the table's own encoding
+//	then       entries, 16 bytes per slot: key (uint64), value (uint64)
+//
+// Keys are hashed with mappedHash rather than a random maphash seed so the
+// placement recorded in the buffer stays valid across processes.
+const (
+	mappedMagic      = "SWTM"
+	mappedVersion    = 2
+	mappedHeaderSize = 24
+	mappedEntrySize  = 16
+)
+
+var errMappedLayout = errors.New("swisstable: invalid mapped layout")
+
+// mappedHash is a fixed splitmix64 finalizer over uint64 keys. Keys of any
+// other type hash to zero and are never found.
+func mappedHash(key any) uint64 {
+	k, ok := key.(uint64)
+	if !ok {
+		return 0
+	}
+	return mixMapped(k)
+}
+
+// mixMapped is the splitmix64 finalizer behind mappedHash
+func mixMapped(k uint64) uint64 {
+	k ^= k >> 30
+	k *= 0xbf58476d1ce4e5b9
+	k ^= k >> 27
+	k *= 0x94d049bb133111eb
+	k ^= k >> 31
+	return k
+}
+
+// MappedSize returns the number of bytes BuildMapped needs for n entries
+func MappedSize(n int) int {
+	slots := New().capacityFor(n)
+	return mappedHeaderSize + slots + slots*mappedEntrySize
+}
+
+// BuildMapped writes a table holding the given uint64 pairs into data, which
+// must be at least MappedSize(len(pairs)) bytes, and returns the number of
+// bytes written. Later pairs win when keys repeat.
+func BuildMapped(data []byte, pairs [][2]uint64) (int, error) {
+	st := New()
+	st.hash = mappedHash
+	if err := st.resizeTo(st.capacityFor(len(pairs))); err != nil {
+		return 0, err
+	}
+	for _, p := range pairs {
+		st.Put(p[0], p[1])
+	}
+
+	slots := len(st.entries)
+	n := mappedHeaderSize + slots + slots*mappedEntrySize
+	if len(data) < n {
+		return 0, errors.New("swisstable: buffer too small for mapped table")
+	}
+
+	copy(data, mappedMagic)
+	binary.LittleEndian.PutUint32(data[4:], mappedVersion)
+	binary.LittleEndian.PutUint64(data[8:], uint64(slots))
+	binary.LittleEndian.PutUint64(data[16:], uint64(st.size))
+
+	ctrl := data[mappedHeaderSize : mappedHeaderSize+slots]
+	for i, group := range st.metadata {
+		copy(ctrl[i*groupSize:], group.bytes[:])
+	}
+
+	entries := data[mappedHeaderSize+slots : n]
+	for idx, e := range st.entries {
+		if !isFull(ctrl[idx]) {
+			continue
+		}
+		binary.LittleEndian.PutUint64(entries[idx*mappedEntrySize:], e.key.(uint64))
+		binary.LittleEndian.PutUint64(entries[idx*mappedEntrySize+8:], e.value.(uint64))
+	}
+	return n, nil
+}
+
+// parseMapped checks the header of a buffer written by BuildMapped and
+// returns its live entry count, control bytes and entry records
+func parseMapped(data []byte) (size int, ctrl, raw []byte, err error) {
+	if len(data) < mappedHeaderSize || string(data[:4]) != mappedMagic {
+		return 0, nil, nil, errMappedLayout
+	}
+	if binary.LittleEndian.Uint32(data[4:]) != mappedVersion {
+		return 0, nil, nil, errors.New("swisstable: unsupported mapped layout version")
+	}
+	slots64 := binary.LittleEndian.Uint64(data[8:])
+	size64 := binary.LittleEndian.Uint64(data[16:])
+	if slots64 == 0 || slots64%groupSize != 0 || size64 > slots64 ||
+		slots64 > uint64(len(data)-mappedHeaderSize)/(1+mappedEntrySize) {
+		return 0, nil, nil, errMappedLayout
+	}
+	slots := int(slots64)
+
+	ctrl = data[mappedHeaderSize : mappedHeaderSize+slots]
+	raw = data[mappedHeaderSize+slots : mappedHeaderSize+slots+slots*mappedEntrySize]
+	return int(size64), ctrl, raw, nil
+}
+
+// OpenMapped opens a table written by BuildMapped as a regular, frozen
+// SwissTable. Only the control bytes are used in place, so data must outlive
+// the table. Every key and value is decoded onto the heap and rehashed to
+// verify the layout, which costs time and memory linear in the table size;
+// OpenMappedView reads the buffer in place instead. Any modification panics.
+func OpenMapped(data []byte) (*SwissTable, error) {
+	size, ctrl, raw, err := parseMapped(data)
+	if err != nil {
+		return nil, err
+	}
+	slots := len(ctrl)
+
+	st := New()
+	st.hash = mappedHash
+	st.groupCount = slots / groupSize
+	st.metadata = unsafe.Slice((*metadata)(unsafe.Pointer(&ctrl[0])), st.groupCount)
+	st.entries = make([]entry, slots)
+
+	live := 0
+	for idx, h2 := range ctrl {
+		if !isFull(h2) {
+			if h2 != ctrlEmpty && h2 != ctrlDeleted {
+				return nil, errMappedLayout
+			}
+			continue
+		}
+		key := binary.LittleEndian.Uint64(raw[idx*mappedEntrySize:])
+		h1, wantH2 := st.hashKey(key)
+		if h2 != wantH2 {
+			return nil, errMappedLayout
+		}
+		st.entries[idx] = entry{
+			key:    key,
+			value:  binary.LittleEndian.Uint64(raw[idx*mappedEntrySize+8:]),
+			h2Hash: h2,
+			fp:     fingerprint(h1),
+		}
+		live++
+	}
+	if live != size {
+		return nil, errMappedLayout
+	}
+	st.size = live
+	st.recountOccupancy()
+	st.frozen = true
+	return st, nil
+}
+
+// MappedTable is a read-only table that looks keys and values up directly in
+// a buffer written by BuildMapped, without decoding anything onto the heap
+type MappedTable struct {
+	// Control bytes, aliasing the buffer
+	metadata []metadata
+	// Entry records, aliasing the buffer
+	raw []byte
+	// Number of live entries recorded in the header
+	size int
+}
+
+// OpenMappedView opens a buffer written by BuildMapped without copying it, so
+// data may be a read-only memory mapping and must outlive the view. Only the
+// header is checked, so opening takes constant time; a buffer corrupted past
+// the header yields wrong results rather than an error.
+func OpenMappedView(data []byte) (*MappedTable, error) {
+	size, ctrl, raw, err := parseMapped(data)
+	if err != nil {
+		return nil, err
+	}
+	return &MappedTable{
+		metadata: unsafe.Slice((*metadata)(unsafe.Pointer(&ctrl[0])), len(ctrl)/groupSize),
+		raw:      raw,
+		size:     size,
+	}, nil
+}
+
+// Get retrieves a value by key, reading the key and value records in place
+func (mt *MappedTable) Get(key uint64) (uint64, bool) {
+	hash := mixMapped(key)
+	h1, h2 := hash>>h2Bits, uint8(hash&h2Mask)
+
+	groupCount := uint64(len(mt.metadata))
+	home := h1 % groupCount
+	for groupIdx := home; ; {
+		group := &mt.metadata[groupIdx]
+		for matches := matchGroup(group, h2); matches != 0; matches &= matches - 1 {
+			rec := mt.raw[(int(groupIdx)*groupSize+bits.TrailingZeros16(matches))*mappedEntrySize:]
+			if binary.LittleEndian.Uint64(rec) == key {
+				return binary.LittleEndian.Uint64(rec[8:]), true
+			}
+		}
+		// BuildMapped fills the first free slot, so the key cannot be further on
+		if matchEmpty(group) != 0 {
+			return 0, false
+		}
+		groupIdx = (groupIdx + 1) % groupCount
+		if groupIdx == home {
+			return 0, false
+		}
+	}
+}
+
+// Size returns the number of entries recorded in the buffer
+func (mt *MappedTable) Size() int {
+	return mt.size
+}
@@ -0,0 +1,126 @@
+package swisstable
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOpenMapped(t *testing.T) {
+	pairs := make([][2]uint64, 300)
+	for i := range pairs {
+		pairs[i] = [2]uint64{uint64(i) * 7919, uint64(i)}
+	}
+
+	buf := make([]byte, MappedSize(len(pairs)))
+	n, err := BuildMapped(buf, pairs)
+	if err != nil {
+		t.Fatalf("BuildMapped failed: %v", err)
+	}
+	if n != len(buf) {
+		t.Errorf("Expected %d bytes written, got %d", len(buf), n)
+	}
+
+	st, err := OpenMapped(buf)
+	if err != nil {
+		t.Fatalf("OpenMapped failed: %v", err)
+	}
+	if st.Size() != len(pairs) {
+		t.Errorf("Expected size %d, got %d", len(pairs), st.Size())
+	}
+	for _, p := range pairs {
+		if v, ok := st.Get(p[0]); !ok || v != p[1] {
+			t.Errorf("Key %d: expected (%d, true), got (%v, %v)", p[0], p[1], v, ok)
+		}
+	}
+	if _, ok := st.Get(uint64(1)); ok {
+		t.Error("Expected miss for an absent key")
+	}
+
+	// Control bytes are read in place from the buffer
+	if &st.metadata[0].bytes[0] != &buf[mappedHeaderSize] {
+		t.Error("Expected metadata to alias the mapped buffer")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected Put on a mapped table to panic")
+			}
+		}()
+		st.Put(uint64(1), uint64(1))
+	}()
+}
+
+func TestOpenMappedRejectsCorruptData(t *testing.T) {
+	pairs := [][2]uint64{{1, 10}, {2, 20}}
+	buf := make([]byte, MappedSize(len(pairs)))
+	if _, err := BuildMapped(buf, pairs); err != nil {
+		t.Fatalf("BuildMapped failed: %v", err)
+	}
+
+	if _, err := BuildMapped(make([]byte, 10), pairs); err == nil {
+		t.Error("Expected BuildMapped to reject a short buffer")
+	}
+
+	for name, corrupt := range map[string]func([]byte){
+		"magic":     func(b []byte) { b[0] = 'X' },
+		"truncated": func(b []byte) { b[8] = 0xFF },
+		"size":      func(b []byte) { b[16] = 3 },
+		"control": func(b []byte) {
+			for i := mappedHeaderSize; ; i++ {
+				if b[i] == ctrlEmpty {
+					b[i] = ctrlSentinel
+					return
+				}
+			}
+		},
+	} {
+		bad := append([]byte(nil), buf...)
+		corrupt(bad)
+		if _, err := OpenMapped(bad); !errors.Is(err, errMappedLayout) {
+			t.Errorf("%s: expected errMappedLayout, got %v", name, err)
+		}
+	}
+}
+
+func TestOpenMappedView(t *testing.T) {
+	pairs := make([][2]uint64, 300)
+	for i := range pairs {
+		pairs[i] = [2]uint64{uint64(i) * 7919, uint64(i)}
+	}
+	buf := make([]byte, MappedSize(len(pairs)))
+	if _, err := BuildMapped(buf, pairs); err != nil {
+		t.Fatalf("BuildMapped failed: %v", err)
+	}
+
+	mt, err := OpenMappedView(buf)
+	if err != nil {
+		t.Fatalf("OpenMappedView failed: %v", err)
+	}
+	if mt.Size() != len(pairs) {
+		t.Errorf("Expected size %d, got %d", len(pairs), mt.Size())
+	}
+	for _, p := range pairs {
+		if v, ok := mt.Get(p[0]); !ok || v != p[1] {
+			t.Errorf("Key %d: expected (%d, true), got (%v, %v)", p[0], p[1], v, ok)
+		}
+	}
+	if _, ok := mt.Get(1); ok {
+		t.Error("Expected miss for an absent key")
+	}
+
+	// Lookups read the buffer in place and never allocate
+	if allocs := testing.AllocsPerRun(100, func() { mt.Get(pairs[7][0]) }); allocs != 0 {
+		t.Errorf("Expected Get not to allocate, got %v allocations", allocs)
+	}
+	slots := len(mt.metadata) * groupSize
+	if &mt.raw[0] != &buf[mappedHeaderSize+slots] {
+		t.Error("Expected the entry records to alias the mapped buffer")
+	}
+
+	bad := append([]byte(nil), buf...)
+	bad[8] = 0xFF
+	if _, err := OpenMappedView(bad); !errors.Is(err, errMappedLayout) {
+		t.Errorf("Expected errMappedLayout for a truncated buffer, got %v", err)
+	}
+}
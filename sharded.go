@@ -0,0 +1,134 @@
+package swisstable
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// ShardedSwissTable spreads keys over independent SwissTables, each behind
+// its own lock, so writers touching different shards do not contend. A key's
+// shard is picked by the default hash under a seed of the sharded table's
+// own; every shard hashes with a separate seed, so keys within a shard are
+// not correlated.
+type ShardedSwissTable struct {
+	seed   maphash.Seed
+	shards []shard
+}
+
+// shard is one lock and the table it guards
+type shard struct {
+	mu    sync.RWMutex
+	table *SwissTable
+}
+
+// NewSharded creates an empty ShardedSwissTable with the given number of
+// shards. Fewer than one shard is treated as one.
+func NewSharded(shards int) *ShardedSwissTable {
+	sh := &ShardedSwissTable{
+		seed:   maphash.MakeSeed(),
+		shards: make([]shard, max(shards, 1)),
+	}
+	for i := range sh.shards {
+		sh.shards[i].table = New()
+	}
+	return sh
+}
+
+// NewShardedWithSeed creates an empty ShardedSwissTable whose shard choice
+// and per-shard hashes all derive from base, so tables built with the same
+// base seed, shard count and operations have identical layouts in every
+// shard. Shard i hashes a key by remixing its base hash with i, so every
+// shard still hashes differently. Like any custom hasher, this opts the
+// shards out of reseeding under SetMaxComparisons.
+func NewShardedWithSeed(shards int, base maphash.Seed) *ShardedSwissTable {
+	sh := NewSharded(shards)
+	sh.seed = base
+	for i := range sh.shards {
+		sh.shards[i].table.hash = derivedShardHash(base, i)
+	}
+	return sh
+}
+
+// derivedShardHash returns the hash of shard index under base: the key's
+// default hash, xored with a per-shard constant and remixed so none of the
+// bits that picked the shard carry over
+func derivedShardHash(base maphash.Seed, index int) func(key any) uint64 {
+	salt := uint64(index+1) * 0x9e3779b97f4a7c15
+	return func(key any) uint64 {
+		hash, _ := seededHash(key, base)
+		return mixMapped(hash ^ salt)
+	}
+}
+
+// shardFor returns the shard responsible for key
+func (sh *ShardedSwissTable) shardFor(key any) *shard {
+	hash, _ := seededHash(key, sh.seed)
+	return &sh.shards[hash%uint64(len(sh.shards))]
+}
+
+// Get retrieves a value by key
+func (sh *ShardedSwissTable) Get(key any) (any, bool) {
+	s := sh.shardFor(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.table.Get(key)
+}
+
+// Put inserts or updates a key-value pair
+func (sh *ShardedSwissTable) Put(key, value any) {
+	s := sh.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.table.Put(key, value)
+}
+
+// Delete removes a key-value pair
+func (sh *ShardedSwissTable) Delete(key any) bool {
+	s := sh.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.table.Delete(key)
+}
+
+// Size returns the number of elements across all shards. Shards are counted
+// one at a time, so with concurrent writers the total is only approximate.
+func (sh *ShardedSwissTable) Size() int {
+	total := 0
+	for i := range sh.shards {
+		s := &sh.shards[i]
+		s.mu.RLock()
+		total += s.table.Size()
+		s.mu.RUnlock()
+	}
+	return total
+}
+
+// ShardBalance returns the live size of each shard, each read under that
+// shard's read lock
+func (sh *ShardedSwissTable) ShardBalance() []int {
+	sizes := make([]int, len(sh.shards))
+	for i := range sh.shards {
+		s := &sh.shards[i]
+		s.mu.RLock()
+		sizes[i] = s.table.Size()
+		s.mu.RUnlock()
+	}
+	return sizes
+}
+
+// ShardSkew returns the largest shard size divided by the average, 1 for a
+// perfectly even spread and the shard count when one shard holds every key.
+// It returns 0 for an empty table. Like Size, it is approximate under
+// concurrent writers.
+func (sh *ShardedSwissTable) ShardSkew() float64 {
+	sizes := sh.ShardBalance()
+	total, largest := 0, 0
+	for _, n := range sizes {
+		total += n
+		largest = max(largest, n)
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(largest) * float64(len(sizes)) / float64(total)
+}
@@ -0,0 +1,117 @@
+package swisstable
+
+import (
+	"hash/maphash"
+	"math/rand"
+	"testing"
+)
+
+// TestShardedSwissTable mixes operations from many goroutines, run it with
+// -race to check the locking
+func TestShardedSwissTable(t *testing.T) {
+	sh := NewSharded(8)
+	sh.StressTest(t, 16, 2000)
+
+	used := 0
+	for i := range sh.shards {
+		if sh.shards[i].table.Size() > 0 {
+			used++
+		}
+		checkInvariants(t, sh.shards[i].table)
+	}
+	if used != len(sh.shards) {
+		t.Errorf("Expected keys in all %d shards, got %d", len(sh.shards), used)
+	}
+	if n := len(NewSharded(0).shards); n != 1 {
+		t.Errorf("Expected NewSharded(0) to have 1 shard, got %d", n)
+	}
+}
+
+func TestShardBalance(t *testing.T) {
+	sh := NewSharded(8)
+	if skew := sh.ShardSkew(); skew != 0 {
+		t.Errorf("Expected skew 0 for an empty table, got %v", skew)
+	}
+
+	// Every key lands in shard 3
+	hot := &sh.shards[3]
+	for k, n := 0, 0; n < 500; k++ {
+		if sh.shardFor(k) == hot {
+			sh.Put(k, k)
+			n++
+		}
+	}
+	balance := sh.ShardBalance()
+	for i, n := range balance {
+		want := 0
+		if i == 3 {
+			want = 500
+		}
+		if n != want {
+			t.Errorf("Shard %d: expected %d entries, got %d", i, want, n)
+		}
+	}
+	if skew := sh.ShardSkew(); skew != 8 {
+		t.Errorf("Expected skew 8 with one hot shard, got %v", skew)
+	}
+
+	even := NewSharded(8)
+	for k := 0; k < 8000; k++ {
+		even.Put(k, k)
+	}
+	if skew := even.ShardSkew(); skew < 1 || skew > 1.2 {
+		t.Errorf("Expected skew close to 1 for spread keys, got %v", skew)
+	}
+}
+
+func TestNewShardedWithSeed(t *testing.T) {
+	base := maphash.MakeSeed()
+	a, b := NewShardedWithSeed(4, base), NewShardedWithSeed(4, base)
+	for k := 0; k < 1000; k++ {
+		a.Put(k, k)
+		b.Put(k, k)
+	}
+	for k := 0; k < 1000; k += 3 {
+		a.Delete(k)
+		b.Delete(k)
+	}
+
+	for i := range a.shards {
+		if got, want := b.shards[i].table.Visualize(), a.shards[i].table.Visualize(); got != want {
+			t.Errorf("Shard %d: layouts differ under the same base seed:\n%s\nexpected:\n%s", i, got, want)
+		}
+		checkInvariants(t, a.shards[i].table)
+	}
+}
+
+// BenchmarkShardedVsLocked compares write-heavy parallel throughput of the
+// single-lock wrapper and a sharded table, run it with -race to also check
+// both for races
+func BenchmarkShardedVsLocked(b *testing.B) {
+	const keys = 1 << 16
+	for _, bc := range []struct {
+		name  string
+		table interface {
+			Put(key, value any)
+			Get(key any) (any, bool)
+		}
+	}{
+		{"single-lock", NewConcurrent()},
+		{"sharded", NewSharded(32)},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					k := rng.Intn(keys)
+					// Four writes for every read
+					if rng.Intn(5) == 0 {
+						bc.table.Get(k)
+					} else {
+						bc.table.Put(k, k)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package swisstable
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// Number of keys each StressTest goroutine owns
+const stressKeysPerGoroutine = 64
+
+// stressTable is the part of the concurrent wrappers StressTest exercises
+type stressTable interface {
+	Get(key any) (any, bool)
+	Put(key, value any)
+	Delete(key any) bool
+	Size() int
+}
+
+// rangeTable is implemented by wrappers with a Range, which StressTest then
+// checks as well
+type rangeTable interface {
+	Range(f func(key, value any) bool)
+}
+
+// StressTest runs a mixed workload of ops operations from each of goroutines
+// goroutines and cross-checks every result against a mutex-guarded map. Run
+// it under -race to validate the locking as well as the contents.
+func (ct *ConcurrentSwissTable) StressTest(t *testing.T, goroutines, ops int) {
+	t.Helper()
+	stressTest(t, ct, goroutines, ops)
+}
+
+// StressTest is ConcurrentSwissTable.StressTest for a sharded table, where
+// the goroutines' keys spread over every shard
+func (sh *ShardedSwissTable) StressTest(t *testing.T, goroutines, ops int) {
+	t.Helper()
+	stressTest(t, sh, goroutines, ops)
+}
+
+// stressTest drives table from several goroutines. Each goroutine writes
+// only the keys it owns, so its view of those keys is exact, while it reads
+// keys owned by the others to race with their writes. Values record their
+// key, which any read can check. The table must start empty.
+func stressTest(t *testing.T, table stressTable, goroutines, ops int) {
+	t.Helper()
+	goroutines = max(goroutines, 1)
+	var mu sync.Mutex
+	shadow := make(map[any]any)
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(g)))
+			for i := 0; i < ops; i++ {
+				k := rng.Intn(stressKeysPerGoroutine)*goroutines + g
+				mu.Lock()
+				want, wantOK := shadow[k]
+				mu.Unlock()
+
+				switch rng.Intn(6) {
+				case 0, 1:
+					v := [2]int{k, i}
+					table.Put(k, v)
+					mu.Lock()
+					shadow[k] = v
+					mu.Unlock()
+				case 2:
+					if got := table.Delete(k); got != wantOK {
+						t.Errorf("Delete(%d): expected %v, got %v", k, wantOK, got)
+					}
+					mu.Lock()
+					delete(shadow, k)
+					mu.Unlock()
+				case 3:
+					if got, ok := table.Get(k); ok != wantOK || got != want {
+						t.Errorf("Get(%d): expected (%v, %v), got (%v, %v)", k, want, wantOK, got, ok)
+					}
+				case 4:
+					// A key another goroutine is writing
+					other := rng.Intn(stressKeysPerGoroutine * goroutines)
+					if got, ok := table.Get(other); ok && got.([2]int)[0] != other {
+						t.Errorf("Get(%d): value %v belongs to another key", other, got)
+					}
+				case 5:
+					table.Size()
+					if r, ok := table.(rangeTable); ok {
+						r.Range(func(key, value any) bool {
+							if value.([2]int)[0] != key {
+								t.Errorf("Range: key %v holds the value of key %d", key, value.([2]int)[0])
+							}
+							return true
+						})
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if table.Size() != len(shadow) {
+		t.Errorf("Expected size %d, got %d", len(shadow), table.Size())
+	}
+	for k, want := range shadow {
+		if got, ok := table.Get(k); !ok || got != want {
+			t.Errorf("Key %v: expected (%v, true), got (%v, %v)", k, want, got, ok)
+		}
+	}
+}
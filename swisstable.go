@@ -1,10 +1,21 @@
 package swisstable
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"hash/maphash"
+	"iter"
+	"maps"
+	"math"
 	"math/bits"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unsafe"
 )
 
@@ -15,12 +26,52 @@ const (
 	initialSize = 16
 	// Load factor threshold for resizing
 	loadFactor = 0.75
+	// Default factor the slot count is multiplied by on each automatic resize
+	growthFactor = 2
+	// Low bits of a Scan cursor holding the slot index, the rest hold the epoch
+	cursorSlotBits = 32
+	cursorSlotMask = 1<<cursorSlotBits - 1
 	// Number of bits used for the H2 hash
 	h2Bits = 7
 	// Mask for extracting H2 hash
 	h2Mask = (1 << h2Bits) - 1
+	// Number of inserts observed before the adaptive load factor is re-evaluated
+	adaptiveWindow = 32
+	// Average probe length (in groups) above which the load factor is lowered
+	adaptiveProbeThreshold = 2.0
+	// Amount the load factor is lowered each time the threshold is exceeded
+	adaptiveStep = 0.05
+	// Lowest load factor the adaptive mode will settle on
+	adaptiveMinLoad = 0.5
+	// Percentile of expected sizes covered by ReserveFromHistogram
+	histogramPercentile = 0.99
+	// Number of hash bits selecting a HyperLogLog register (2^14 registers)
+	hllPrecision = 14
 )
 
+// Control bytes follow Abseil's encoding: a full slot stores its 7-bit H2
+// with the high bit clear, every special value has the high bit set
+const (
+	// Slot that has never held an entry, ends a probe sequence
+	ctrlEmpty = 0x80
+	// Slot whose entry was deleted, reusable but does not end a probe sequence
+	ctrlDeleted = 0xFE
+	// End-of-table marker reserved by the encoding, never stored in a group
+	ctrlSentinel = 0xFF
+)
+
+// ErrValueTooLarge is returned by PutErr when a value exceeds the cap set with
+// SetMaxValueSize
+var ErrValueTooLarge = errors.New("swisstable: value too large")
+
+// ErrCapacityFrozen is returned by PutErr when an insert would need to grow a
+// table whose capacity is locked with FreezeCapacity
+var ErrCapacityFrozen = errors.New("swisstable: capacity is frozen")
+
+// ErrStaleCursor is returned by ScanErr, and is the panic value of Scan, when
+// given a cursor from before the table was cleared, rehashed or reloaded
+var ErrStaleCursor = errors.New("swisstable: scan cursor used after the table was cleared or resized")
+
 // metadata represents a SIMD-friendly group of control bytes
 type metadata struct {
 	bytes [groupSize]uint8
@@ -38,6 +89,67 @@ type SwissTable struct {
 	hashSeed maphash.Seed
 	// Number of groups (len(metadata))
 	groupCount int
+	// Load factor threshold currently in effect
+	maxLoad float64
+	// Factor the slot count is multiplied by on each automatic resize
+	growth int
+	// Whether maxLoad adapts to observed probe lengths
+	adaptive bool
+	// Probe lengths observed in the current adaptive window
+	probeSum   int
+	probeCount int
+	// HyperLogLog registers for ApproxDistinctKeys, nil when disabled
+	hll []uint8
+	// Optional cheap inequality test run before the full key compare
+	cheapNeq func(stored, query any) bool
+	// Whether inserts use Robin Hood displacement
+	robinHood bool
+	// Generation stamped on the most recent Put
+	generation uint64
+	// Canonical copies of string keys, nil unless created with NewInterned
+	interned map[string]string
+	// Whether operations are timed (see EnableInstrumentation)
+	instrumented bool
+	latencies    Latencies
+	bytesHashed  uint64
+	resizeTime   time.Duration
+	// Set by Freeze, mutations panic once true
+	frozen bool
+	// Optional check run on every key before it is inserted
+	keyValidator func(key any) error
+	// Largest accepted value size as measured by valueSizeOf, 0 for no cap
+	maxValueSize int
+	valueSizeOf  func(value any) int
+	// Custom hash and equality functions, nil for maphash and ==
+	hash  func(key any) uint64
+	equal func(a, b any) bool
+	// Number of independent group ranges keys are confined to, 0 for none
+	partitions int
+	// Whether integer keys are used as their own hash (see NewPreDistributed)
+	preDistributed bool
+	// Entries removed by SoftDelete, nil until the first one
+	softDeleted *SwissTable
+	// Most groups a Get may probe before reporting a miss, 0 for no limit
+	maxLookupProbes int
+	// Most groups any Get has probed, recorded under instrumentation
+	maxProbed int
+	// Shared value copies, nil unless created with NewDedupValues
+	dedup *valueStore
+	// Set between FreezeCapacity and UnfreezeCapacity, resizes fail while true
+	capacityFrozen bool
+	// Number of full slots in each group, kept in step by setCtrl
+	occupancy []int
+	// Number of ctrlDeleted slots, kept in step by setCtrl. They lengthen
+	// probes like live entries, so they count toward the load factor.
+	tombstones int
+	// Bumped whenever every entry may move or vanish, stamped into cursors
+	epoch uint64
+	// Most failed key compares a lookup makes before reseeding, 0 for no limit
+	maxComparisons int
+	// Number of reseeds triggered by the comparison bound
+	reseeds int
+	// Most key compares any lookup has made, recorded under instrumentation
+	maxCompared int
 }
 
 // entry represents a key-value pair in the table
@@ -46,51 +158,319 @@ type entry struct {
 	value any
 	// H2 hash helps in SIMD comparison
 	h2Hash uint8
+	// Caller-supplied metadata set by PutMeta, ignored by hashing and equality
+	meta any
+	// Table generation at the last Put of this entry
+	gen uint64
+	// Secondary fingerprint checked after an H2 match, before the full compare
+	fp uint32
 }
 
 // New creates a new SwissTable with initial capacity
 func New() *SwissTable {
-	groupCount := initialSize / groupSize
+	return newSized(initialSize)
+}
+
+// NewWithCapacity creates a SwissTable sized up front to hold n entries
+// without resizing at the default load factor. n <= 0 behaves like New.
+func NewWithCapacity(n int) *SwissTable {
+	st := &SwissTable{maxLoad: loadFactor}
+	return newSized(st.capacityFor(n))
+}
+
+// newSized creates an empty SwissTable with slots slots, a multiple of
+// groupSize
+func newSized(slots int) *SwissTable {
+	groupCount := slots / groupSize
 	st := &SwissTable{
-		entries:    make([]entry, initialSize),
+		entries:    make([]entry, slots),
 		metadata:   make([]metadata, groupCount),
+		occupancy:  make([]int, groupCount),
 		size:       0,
 		hashSeed:   maphash.MakeSeed(),
 		groupCount: groupCount,
+		maxLoad:    loadFactor,
+		growth:     growthFactor,
 	}
 	// Initialize all metadata bytes to empty
 	for i := range st.metadata {
 		for j := range st.metadata[i].bytes {
-			st.metadata[i].bytes[j] = 0
+			st.metadata[i].bytes[j] = ctrlEmpty
 		}
 	}
 	return st
 }
 
+// NewInterned creates a SwissTable that canonicalizes string keys on Put, so
+// equal key strings built from different sources share one backing array.
+// The intern pool only grows; strings stay pooled after their key is deleted.
+func NewInterned() *SwissTable {
+	st := New()
+	st.interned = make(map[string]string)
+	return st
+}
+
+// NewPreDistributed creates a SwissTable for integer keys that are already
+// uniformly distributed, such as content hashes. An integer key is used as
+// its own hash after a light mix, skipping maphash; keys of other types are
+// hashed as usual. Keys with poorly spread bits will cluster.
+func NewPreDistributed() *SwissTable {
+	st := New()
+	st.preDistributed = true
+	return st
+}
+
+// internKey returns the pooled copy of a string key
+func (st *SwissTable) internKey(key any) any {
+	s, ok := key.(string)
+	if !ok {
+		return key
+	}
+	if canonical, ok := st.interned[s]; ok {
+		return canonical
+	}
+	st.interned[s] = s
+	return s
+}
+
+// NewPartitioned creates a SwissTable whose groups are split into the given
+// number of equal contiguous ranges. Each key is assigned a partition by its
+// hash and only ever probes that partition's groups, which keeps a key's
+// accesses within one region of memory at the cost of less probe
+// flexibility: a full partition grows the whole table.
+func NewPartitioned(partitions int) *SwissTable {
+	st := New()
+	if partitions > 1 {
+		st.partitions = partitions
+		_ = st.resizeTo(partitions * groupSize)
+	}
+	return st
+}
+
+// PartitionOf returns the partition key is confined to, always 0 for a table
+// that is not partitioned
+func (st *SwissTable) PartitionOf(key any) int {
+	if st.partitions <= 1 {
+		return 0
+	}
+	h1, _ := st.hashKey(key)
+	return int(h1 % uint64(st.partitions))
+}
+
+// newLike creates an empty table with st's hashing, equality, load factor,
+// growth factor and partitioning
+func (st *SwissTable) newLike() *SwissTable {
+	like := NewPartitioned(st.partitions)
+	like.hashSeed = st.hashSeed
+	like.hash = st.hash
+	like.preDistributed = st.preDistributed
+	like.equal = st.equal
+	like.maxLoad = st.maxLoad
+	like.growth = st.growth
+	return like
+}
+
 // hashKey generates both H1 (group index) and H2 (metadata) hashes
 func (st *SwissTable) hashKey(key any) (h1 uint64, h2 uint8) {
-	var h maphash.Hash
-	h.SetSeed(st.hashSeed)
-	fmt.Fprintf(&h, "%v", key)
-	hash := h.Sum64()
+	var hash uint64
+	var hashed bool
+	if st.hash != nil {
+		hash, hashed = st.hash(key), true
+	} else if st.preDistributed {
+		hash, hashed = preDistributedHash(key)
+	}
+	if !hashed {
+		var n int
+		hash, n = seededHash(key, st.hashSeed)
+		if st.instrumented {
+			st.bytesHashed += uint64(n)
+		}
+	}
 
 	// H1 determines the group (high bits)
 	h1 = hash >> h2Bits
 
-	// H2 is used for SIMD matching (low bits)
+	// H2 is used for SIMD matching (low bits), its high bit is always clear
 	h2 = uint8(hash & h2Mask)
 
-	// Ensure h2 is never zero (zero is used for empty slots)
-	if h2 == 0 {
-		h2 = 1
+	return h1, h2
+}
+
+// Type discriminators written ahead of the key bytes by seededHash, so keys
+// of different types never share a hash stream
+const (
+	hashTagFormatted = iota
+	hashTagInt
+	hashTagInt64
+	hashTagUint64
+	hashTagString
+	hashTagBytes
+)
+
+// seededHash is the default key hash: maphash over a type tag and the key's
+// bytes, falling back to the key formatted with %v for less common types. It
+// also returns the number of bytes hashed.
+func seededHash(key any, seed maphash.Seed) (uint64, int) {
+	switch k := key.(type) {
+	case int:
+		return wordHash(seed, hashTagInt, uint64(k))
+	case int64:
+		return wordHash(seed, hashTagInt64, uint64(k))
+	case uint64:
+		return wordHash(seed, hashTagUint64, k)
+	case string:
+		var h maphash.Hash
+		h.SetSeed(seed)
+		h.WriteByte(hashTagString)
+		h.WriteString(k)
+		return h.Sum64(), 1 + len(k)
+	case []byte:
+		var h maphash.Hash
+		h.SetSeed(seed)
+		h.WriteByte(hashTagBytes)
+		h.Write(k)
+		return h.Sum64(), 1 + len(k)
 	}
+	return formattedHash(key, seed)
+}
 
-	return h1, h2
+// wordHash hashes a tag byte followed by a 64-bit word
+func wordHash(seed maphash.Seed, tag byte, word uint64) (uint64, int) {
+	var buf [9]byte
+	buf[0] = tag
+	binary.LittleEndian.PutUint64(buf[1:], word)
+	return maphash.Bytes(seed, buf[:]), len(buf)
+}
+
+// formattedHash hashes the key formatted with %v. It is kept out of
+// seededHash because passing the hasher to fmt makes it escape to the heap.
+func formattedHash(key any, seed maphash.Seed) (uint64, int) {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	h.WriteByte(hashTagFormatted)
+	n, _ := fmt.Fprintf(&h, "%v", key)
+	return h.Sum64(), 1 + n
+}
+
+// RingPosition returns the position of key on a 64-bit consistent-hashing
+// ring, using the same hash a default table applies to its keys. Callers that
+// share the seed place every key at the same position.
+func RingPosition(key any, seed maphash.Seed) uint64 {
+	pos, _ := seededHash(key, seed)
+	return pos
+}
+
+// preDistributedHash returns an integer key mixed just enough that its high
+// bits also reach H2, and false for any other key type
+func preDistributedHash(key any) (uint64, bool) {
+	var k uint64
+	switch v := key.(type) {
+	case int:
+		k = uint64(v)
+	case int8:
+		k = uint64(v)
+	case int16:
+		k = uint64(v)
+	case int32:
+		k = uint64(v)
+	case int64:
+		k = uint64(v)
+	case uint:
+		k = uint64(v)
+	case uint8:
+		k = uint64(v)
+	case uint16:
+		k = uint64(v)
+	case uint32:
+		k = uint64(v)
+	case uint64:
+		k = v
+	case uintptr:
+		k = uint64(v)
+	default:
+		return 0, false
+	}
+	k *= 0x9e3779b97f4a7c15
+	return k ^ k>>32, true
+}
+
+// keysEqual compares two keys with the configured equality function
+func (st *SwissTable) keysEqual(a, b any) bool {
+	if st.equal != nil {
+		return st.equal(a, b)
+	}
+	return a == b
+}
+
+// fingerprint derives the 32-bit secondary fingerprint from the top of H1.
+// Group selection uses the low bits of H1, so the two are independent for any
+// realistic table size.
+func fingerprint(h1 uint64) uint32 {
+	return uint32(h1 >> (64 - h2Bits - 32))
+}
+
+// setCtrl writes the control byte of slot idx and keeps the group's
+// occupancy count and the tombstone count in step
+func (st *SwissTable) setCtrl(idx int, ctrl uint8) {
+	groupIdx := idx / groupSize
+	old := st.metadata[groupIdx].bytes[idx%groupSize]
+	switch {
+	case isFull(ctrl) && !isFull(old):
+		st.occupancy[groupIdx]++
+	case !isFull(ctrl) && isFull(old):
+		st.occupancy[groupIdx]--
+	}
+	if old == ctrlDeleted {
+		st.tombstones--
+	}
+	if ctrl == ctrlDeleted {
+		st.tombstones++
+	}
+	st.metadata[groupIdx].bytes[idx%groupSize] = ctrl
+}
+
+// recountOccupancy rebuilds the occupancy counts and the tombstone count from
+// the control bytes, for tables whose metadata was not written through setCtrl
+func (st *SwissTable) recountOccupancy() {
+	st.occupancy = make([]int, len(st.metadata))
+	st.tombstones = 0
+	for i := range st.metadata {
+		st.occupancy[i] = bits.OnesCount16(matchFull(&st.metadata[i]))
+		st.tombstones += bits.OnesCount16(matchGroup(&st.metadata[i], ctrlDeleted))
+	}
+}
+
+// GroupOccupancy returns the number of live entries in each group. The
+// counts are maintained on every insert and delete, so this costs one copy
+// rather than a scan of the control bytes.
+func (st *SwissTable) GroupOccupancy() []int {
+	return slices.Clone(st.occupancy)
+}
+
+// IsSkewed reports whether any single group holds more than threshold of all
+// live entries, a sign of a poor hasher or adversarial keys. It reads the
+// maintained occupancy counts and never scans the control bytes.
+func (st *SwissTable) IsSkewed(threshold float64) bool {
+	if st.size == 0 {
+		return false
+	}
+	for _, n := range st.occupancy {
+		if float64(n)/float64(st.size) > threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// isFull reports whether a control byte belongs to a live entry
+func isFull(ctrl uint8) bool {
+	return ctrl&0x80 == 0
 }
 
 // Simulating SIMD operations
-// Returns a bitmask where each bit represents a matching position
-func (st *SwissTable) matchGroup(group *metadata, h2 uint8) uint16 {
+// Returns a bitmask where each bit represents a position whose control byte
+// equals h2. Passing ctrlEmpty or ctrlDeleted matches those markers instead.
+func matchGroup(group *metadata, h2 uint8) uint16 {
 	// Create a vector with the target H2 hash
 	target := uint64(h2) * 0x0101010101010101
 
@@ -99,17 +479,8 @@ func (st *SwissTable) matchGroup(group *metadata, h2 uint8) uint16 {
 	group2 := *(*uint64)(unsafe.Pointer(&group.bytes[8]))
 
 	// Compare with target to find matches
-	matches1 := group1
-	matches2 := group2
-	if h2 == 0 {
-		// Looking for empty slots
-		matches1 = ^matches1
-		matches2 = ^matches2
-	} else {
-		// Looking for matching H2 hashes
-		matches1 = ^(matches1 ^ target)
-		matches2 = ^(matches2 ^ target)
-	}
+	matches1 := ^(group1 ^ target)
+	matches2 := ^(group2 ^ target)
 
 	// Create match mask (1 bit per matching byte)
 	mask1 := uint8(0)
@@ -128,19 +499,110 @@ func (st *SwissTable) matchGroup(group *metadata, h2 uint8) uint16 {
 	return uint16(mask1) | (uint16(mask2) << 8)
 }
 
+// matchEmpty returns a bitmask of the slots that have never held an entry
+func matchEmpty(group *metadata) uint16 {
+	return matchGroup(group, ctrlEmpty)
+}
+
+// matchEmptyOrDeleted returns a bitmask of the slots free for an insert,
+// which are the ones whose control byte has the high bit set
+func matchEmptyOrDeleted(group *metadata) uint16 {
+	group1 := *(*uint64)(unsafe.Pointer(&group.bytes[0]))
+	group2 := *(*uint64)(unsafe.Pointer(&group.bytes[8]))
+
+	mask := uint16(0)
+	for i := 0; i < 8; i++ {
+		if (group1>>(i*8+7))&1 == 1 {
+			mask |= 1 << i
+		}
+		if (group2>>(i*8+7))&1 == 1 {
+			mask |= 1 << (i + 8)
+		}
+	}
+	return mask
+}
+
+// matchFull returns a bitmask of the slots holding a live entry, which are
+// the ones whose control byte has the high bit clear
+func matchFull(group *metadata) uint16 {
+	return ^matchEmptyOrDeleted(group)
+}
+
 // findSlot finds the appropriate slot for a key using SIMD
 func (st *SwissTable) findSlot(key any) (int, bool) {
-	// Get both hashes
+	h1, idx, found := st.lookup(key, 0)
+	if found {
+		return idx, true
+	}
+	// Key not found, look for a free slot starting from h1's group
+	return st.firstFree(h1), false
+}
+
+// lookup hashes key and finds its slot like lookupHashed. If the probe runs
+// into the bound set by SetMaxComparisons, lookup reseeds and rehashes the
+// table and probes again. When the table cannot be reseeded or the new seed
+// still exceeds the bound, the collisions do not depend on the seed, so the
+// bound is removed rather than rehashing on every later lookup. It returns
+// the key's H1 as of after any rehash.
+func (st *SwissTable) lookup(key any, maxProbes int) (h1 uint64, idx int, found bool) {
 	h1, h2 := st.hashKey(key)
+	idx, found, exceeded := st.lookupBounded(key, h1, h2, maxProbes, st.maxComparisons)
+	if !exceeded {
+		return h1, idx, found
+	}
+	if st.reseed() {
+		h1, h2 = st.hashKey(key)
+		if idx, found, exceeded = st.lookupBounded(key, h1, h2, maxProbes, st.maxComparisons); !exceeded {
+			return h1, idx, found
+		}
+	}
+	st.maxComparisons = 0
+	idx, found = st.lookupHashed(key, h1, h2, maxProbes)
+	return h1, idx, found
+}
+
+// findSlotHashed is findSlot for a key whose hashes are already known
+func (st *SwissTable) findSlotHashed(key any, h1 uint64, h2 uint8) (int, bool) {
+	// First try to find the key
+	if idx, found := st.lookupHashed(key, h1, h2, 0); found {
+		return idx, true
+	}
+
+	// Key not found, look for a free slot starting from h1's group
+	if idx := st.firstFree(h1); idx != -1 {
+		return idx, false
+	}
+	return -1, false // Table (or the key's partition) is full
+}
+
+// lookupHashed returns the slot holding key, probing at most maxProbes groups
+// when maxProbes is positive, and false if the key was not found
+func (st *SwissTable) lookupHashed(key any, h1 uint64, h2 uint8, maxProbes int) (int, bool) {
+	idx, found, _ := st.lookupBounded(key, h1, h2, maxProbes, 0)
+	return idx, found
+}
+
+// lookupBounded is lookupHashed that, when maxCompares is positive, also
+// gives up once that many full key compares have failed and reports that it
+// did so
+func (st *SwissTable) lookupBounded(key any, h1 uint64, h2 uint8, maxProbes, maxCompares int) (idx int, found, exceeded bool) {
+	fp := fingerprint(h1)
 
 	// Find initial group
-	groupIdx := h1 % uint64(st.groupCount)
+	groupIdx := st.homeGroup(h1)
 	originalGroup := groupIdx
 
-	// First try to find the key
+	probes, compares := 0, 0
+	if st.instrumented {
+		defer func() {
+			st.maxProbed = max(st.maxProbed, probes)
+			st.maxCompared = max(st.maxCompared, compares)
+		}()
+	}
 	for {
+		probes++
 		// Get matches within the current group
-		matches := st.matchGroup(&st.metadata[groupIdx], h2)
+		matches := matchGroup(&st.metadata[groupIdx], h2)
 
 		// Check each matching position
 		for matches != 0 {
@@ -152,171 +614,1700 @@ func (st *SwissTable) findSlot(key any) (int, bool) {
 			// Calculate actual index
 			idx := int(groupIdx)*groupSize + pos
 
+			// A 7-bit H2 collides often, the stored fingerprint rules most of
+			// those out without touching the key
+			if st.entries[idx].fp != fp {
+				continue
+			}
+
+			// Skip the full compare when a cheap check already rules it out
+			if st.cheapNeq != nil && st.cheapNeq(st.entries[idx].key, key) {
+				continue
+			}
+
 			// Check if keys match
-			if st.entries[idx].key == key {
-				return idx, true
+			compares++
+			if st.keysEqual(st.entries[idx].key, key) {
+				return idx, true, false
+			}
+			if compares == maxCompares {
+				return -1, false, true
 			}
 		}
 
+		// Inserts fill the first free slot, so the key cannot be past a group
+		// that was never full. Deleted slots leave tombstones and keep probing.
+		if matchEmpty(&st.metadata[groupIdx]) != 0 {
+			break
+		}
+		if probes == maxProbes {
+			break
+		}
+
 		// Move to next group
-		groupIdx = (groupIdx + 1) % uint64(st.groupCount)
+		groupIdx = st.nextGroup(groupIdx)
 		if groupIdx == originalGroup {
 			break
 		}
 	}
+	return -1, false, false
+}
+
+// SetMaxComparisons bounds the full key compares a lookup makes against
+// entries sharing the key's H2 and fingerprint. Once k of them fail, Get,
+// Contains, Delete and the other single-key operations built on them stop
+// probing, rehash the table under a fresh seed and look again, so a
+// collision storm costs one rehash instead of k compares per lookup. Even a
+// Get may therefore modify the table. If the fresh seed does not help, as for
+// keys whose hashes are equal under every seed, or the table cannot be
+// reseeded (a custom or pre-distributed hash, partitioned or frozen tables
+// and tables whose capacity is frozen), the lookup finishes unbounded and the
+// bound is removed. k <= 0 removes the bound.
+func (st *SwissTable) SetMaxComparisons(k int) {
+	st.maxComparisons = max(k, 0)
+}
+
+// Reseeds returns the number of times the bound set by SetMaxComparisons has
+// rehashed the table under a new seed
+func (st *SwissTable) Reseeds() int {
+	return st.reseeds
+}
+
+// reseed rehashes the table, and its soft-deleted entries, under a fresh
+// maphash seed and reports whether it did
+func (st *SwissTable) reseed() bool {
+	if st.hash != nil || st.preDistributed || st.partitions > 1 || st.frozen || st.capacityFrozen {
+		return false
+	}
+	oldSeed := st.hashSeed
+	st.hashSeed = maphash.MakeSeed()
+	if err := st.resizeTo(len(st.entries)); err != nil {
+		st.hashSeed = oldSeed
+		return false
+	}
+	// Lookups probe the side table with the receiver's hashes
+	if st.softDeleted != nil {
+		st.softDeleted.hashSeed = st.hashSeed
+		_ = st.softDeleted.resizeTo(len(st.softDeleted.entries))
+	}
+	st.reseeds++
+	return true
+}
+
+// SetMaxLookupProbes bounds the number of groups a Get probes to k, so a key
+// placed more than k groups past its home is reported missing. This trades
+// correctness for bounded latency and only suits callers that accept such
+// false misses, e.g. in caches. Inserts and deletes still probe fully, so
+// they never duplicate or lose keys. k <= 0 removes the bound.
+func (st *SwissTable) SetMaxLookupProbes(k int) {
+	st.maxLookupProbes = max(k, 0)
+}
 
-	// Key not found, look for an empty slot starting from h1's group
-	groupIdx = h1 % uint64(st.groupCount)
-	for i := uint64(0); i < uint64(st.groupCount); i++ {
-		currGroup := (groupIdx + i) % uint64(st.groupCount)
-		matches := st.matchGroup(&st.metadata[currGroup], 0) // Find empty slots
+// firstFree returns the first empty or deleted slot in the probe sequence for
+// H1, or -1 if every group the key may use is full
+func (st *SwissTable) firstFree(h1 uint64) int {
+	groupIdx := st.homeGroup(h1)
+	for i := 0; i < st.probeSpan(); i++ {
+		matches := matchEmptyOrDeleted(&st.metadata[groupIdx])
 		if matches != 0 {
-			pos := bits.TrailingZeros16(matches)
-			return int(currGroup)*groupSize + pos, false
+			return int(groupIdx)*groupSize + bits.TrailingZeros16(matches)
 		}
+		groupIdx = st.nextGroup(groupIdx)
+	}
+	return -1
+}
+
+// probeSpan returns the number of groups a probe sequence can visit, which is
+// the whole table unless it is partitioned
+func (st *SwissTable) probeSpan() int {
+	if st.partitions > 1 {
+		return st.groupCount / st.partitions
 	}
+	return st.groupCount
+}
+
+// homeGroup returns the group where the probe sequence for H1 starts. In a
+// partitioned table the low H1 bits pick the partition and the rest pick a
+// group inside it.
+func (st *SwissTable) homeGroup(h1 uint64) uint64 {
+	if st.partitions > 1 {
+		span := uint64(st.probeSpan())
+		partition := h1 % uint64(st.partitions)
+		return partition*span + (h1/uint64(st.partitions))%span
+	}
+	return h1 % uint64(st.groupCount)
+}
+
+// nextGroup returns the group probed after groupIdx, wrapping around within
+// the group's partition
+func (st *SwissTable) nextGroup(groupIdx uint64) uint64 {
+	if st.partitions > 1 {
+		span := uint64(st.probeSpan())
+		base := groupIdx / span * span
+		return base + (groupIdx-base+1)%span
+	}
+	return (groupIdx + 1) % uint64(st.groupCount)
+}
+
+// SetCheapNeq installs a fast pre-check run on every H2 match before the full
+// == comparison. When fn reports the stored and queried keys as definitely
+// different the full compare is skipped, which pays off for keys that are
+// expensive to compare such as long strings. fn must never return true for
+// equal keys. Passing nil removes the check.
+func (st *SwissTable) SetCheapNeq(fn func(stored, query any) bool) {
+	st.cheapNeq = fn
+}
 
-	return -1, false // Table is full
+// checkMutable panics if the table has been frozen
+func (st *SwissTable) checkMutable() {
+	if st.frozen {
+		panic("swisstable: modification of frozen table")
+	}
 }
 
+// resizeHook lets tests distort the size chosen by resizeTo to simulate
+// sizing bugs
+var resizeHook func(newSize int) int
+
 // resize grows the table when it becomes too full
-func (st *SwissTable) resize() {
+func (st *SwissTable) resize() error {
+	// Double the size
+	return st.resizeTo(len(st.entries) * st.growth)
+}
+
+// makeRoom frees slots for inserts once live entries and tombstones together
+// reach the load factor. When tombstones are the majority, growing would only
+// spread them over more groups, so it rehashes at the current capacity,
+// which turns every tombstone back into an empty slot.
+func (st *SwissTable) makeRoom() error {
+	if st.tombstones >= st.size {
+		return st.resizeTo(len(st.entries))
+	}
+	return st.resize()
+}
+
+// growFor grows the table, rehashing once, so that size entries fit without
+// resizing. It never shrinks the table.
+func (st *SwissTable) growFor(size int) {
+	if slots := st.capacityFor(size); slots > len(st.entries) {
+		// On failure the table keeps its current capacity and grows later
+		_ = st.resizeTo(slots)
+	}
+}
+
+// ResizeTo rehashes the table into exactly capacity slots, rounded up to a
+// whole number of groups (per partition, if partitioned). It refuses a
+// capacity that cannot hold the current size within the load factor.
+func (st *SwissTable) ResizeTo(capacity int) error {
+	unit := groupSize * max(st.partitions, 1)
+	if capacity <= 0 {
+		return fmt.Errorf("swisstable: invalid capacity %d", capacity)
+	}
+	slots := (capacity + unit - 1) / unit * unit
+	if float64(st.size) > st.maxLoad*float64(slots) {
+		return fmt.Errorf("swisstable: %d slots cannot hold %d entries at load factor %.2f", slots, st.size, st.maxLoad)
+	}
+	return st.resizeTo(slots)
+}
+
+// NextCapacity returns the slot count the table will have after its next
+// automatic resize
+func (st *SwissTable) NextCapacity() int {
+	return st.groupsFor(len(st.entries)*st.growth) * groupSize
+}
+
+// ResizePolicy describes when and how the table grows: the growth factor,
+// the load factor currently in effect and the capacity after the next resize
+func (st *SwissTable) ResizePolicy() string {
+	return fmt.Sprintf("grow x%d when size exceeds %.2f of %d slots, next capacity %d slots",
+		st.growth, st.maxLoad, len(st.entries), st.NextCapacity())
+}
+
+// groupsFor returns the number of groups a resize to newSize slots produces
+func (st *SwissTable) groupsFor(newSize int) int {
+	newGroupCount := newSize / groupSize
+	if st.partitions > 1 {
+		// Every partition needs the same number of groups
+		newGroupCount -= newGroupCount % st.partitions
+	}
+	return newGroupCount
+}
+
+// resizeTo rehashes every entry into a table of newSize slots, rounded down
+// to whole groups. If the new layout cannot hold every entry it returns an
+// error and leaves the table exactly as it was.
+func (st *SwissTable) resizeTo(newSize int) error {
+	st.checkMutable()
+	if st.capacityFrozen {
+		return ErrCapacityFrozen
+	}
+	if st.instrumented {
+		defer func(start time.Time) { st.resizeTime += time.Since(start) }(time.Now())
+	}
+	if resizeHook != nil {
+		newSize = resizeHook(newSize)
+	}
+
 	oldEntries := st.entries
 	oldMetadata := st.metadata
+	oldOccupancy := st.occupancy
+	oldGroupCount := st.groupCount
+	oldSize := st.size
+	oldTombstones := st.tombstones
 
-	// Double the size
-	newSize := len(st.entries) * 2
-	newGroupCount := newSize / groupSize
-	st.entries = make([]entry, newSize)
+	newGroupCount := st.groupsFor(newSize)
+	if newGroupCount == 0 {
+		return fmt.Errorf("swisstable: cannot resize to %d slots", newSize)
+	}
+	st.entries = make([]entry, newGroupCount*groupSize)
 	st.metadata = make([]metadata, newGroupCount)
+	st.occupancy = make([]int, newGroupCount)
 	st.groupCount = newGroupCount
 
 	// Reset size as we'll reinsert everything
 	st.size = 0
+	st.tombstones = 0
 
-	// Initialize metadata bytes to empty
+	// Initialize metadata bytes to empty, which also drops every tombstone
 	for i := range st.metadata {
 		for j := range st.metadata[i].bytes {
-			st.metadata[i].bytes[j] = 0
+			st.metadata[i].bytes[j] = ctrlEmpty
 		}
 	}
 
 	// Reinsert all existing entries
 	for groupIdx, group := range oldMetadata {
 		for byteIdx, h2 := range group.bytes {
-			if h2 != 0 { // If not empty
-				oldIdx := groupIdx*groupSize + byteIdx
-				st.Put(oldEntries[oldIdx].key, oldEntries[oldIdx].value)
+			if isFull(h2) {
+				if err := st.reinsert(oldEntries[groupIdx*groupSize+byteIdx]); err != nil {
+					st.entries = oldEntries
+					st.metadata = oldMetadata
+					st.occupancy = oldOccupancy
+					st.groupCount = oldGroupCount
+					st.size = oldSize
+					st.tombstones = oldTombstones
+					return err
+				}
 			}
 		}
 	}
+
+	// Probe lengths from the old layout no longer apply
+	st.probeSum = 0
+	st.probeCount = 0
+	st.epoch++
+	return nil
 }
 
-// Put inserts or updates a key-value pair
-func (st *SwissTable) Put(key, value any) {
-	// Check if we need to resize
-	if float64(st.size+1)/float64(len(st.entries)) > loadFactor {
-		st.resize()
+// reinsert places an entry known to be absent into the first free slot of
+// its probe sequence (or its Robin Hood slot), keeping per-entry state such
+// as metadata. Unlike Put it never resizes, so it fails if the table has no
+// free slot.
+func (st *SwissTable) reinsert(e entry) error {
+	if st.groupCount == 0 {
+		return errors.New("swisstable: no groups to rehash into")
 	}
 
-	idx, found := st.findSlot(key)
+	h1, h2 := st.hashKey(e.key)
+	e.h2Hash = h2
+	e.fp = fingerprint(h1)
+
+	idx := st.firstFree(h1)
 	if idx == -1 {
-		// Table is full after resize (shouldn't happen)
-		panic("table is full")
+		return fmt.Errorf("swisstable: no free slot for rehash into %d slots", len(st.entries))
 	}
-
-	if !found {
-		st.size++
+	if st.robinHood {
+		idx = st.robinHoodSlot(h1)
 	}
 
-	// Calculate group and byte index
-	groupIdx := idx / groupSize
-	byteIdx := idx % groupSize
+	st.entries[idx] = e
+	st.setCtrl(idx, h2)
+	st.size++
+	return nil
+}
 
-	// Get H2 hash for the key
-	_, h2 := st.hashKey(key)
+// capacityFor returns the smallest slot count, rounded up to a whole number
+// of groups per partition, that holds n entries without exceeding the load factor
+func (st *SwissTable) capacityFor(n int) int {
+	unit := groupSize * max(st.partitions, 1)
+	slots := int(math.Ceil(float64(n) / st.maxLoad))
+	slots = (slots + unit - 1) / unit * unit
+	return max(slots, initialSize, unit)
+}
 
-	// Update entry and metadata
-	st.entries[idx] = entry{key: key, value: value, h2Hash: h2}
-	st.metadata[groupIdx].bytes[byteIdx] = h2
+// WasteReport returns the number of live slots, the total slot count, and the
+// smallest capacity that would hold the current size at the load factor. A
+// large gap between the last two means the table is over-provisioned, as
+// happens after many deletions since the table never shrinks on its own.
+func (st *SwissTable) WasteReport() (usedSlots, totalSlots, minSlotsForSize int) {
+	return st.size, len(st.entries), st.capacityFor(st.size)
 }
 
-// Get retrieves a value by key
-func (st *SwissTable) Get(key any) (any, bool) {
-	idx, found := st.findSlot(key)
-	if !found || idx == -1 {
-		return nil, false
-	}
-	return st.entries[idx].value, true
+// Reserve grows the table, rehashing once, so that n more entries fit
+// without resizing. It does nothing if the current capacity already suffices.
+func (st *SwissTable) Reserve(n int) {
+	st.growFor(st.size + n)
 }
 
-// Delete removes a key-value pair
-func (st *SwissTable) Delete(key any) bool {
-	idx, found := st.findSlot(key)
-	if !found || idx == -1 {
-		return false
+// TrimToFit shrinks the table, rehashing once, to the smallest capacity that
+// holds its current size at the load factor, but never below initialSize. It
+// does nothing if the table is already that small. The table never shrinks
+// on its own, so call this after deleting most entries.
+func (st *SwissTable) TrimToFit() {
+	if slots := st.capacityFor(st.size); slots < len(st.entries) {
+		// On failure the table keeps its current capacity
+		_ = st.resizeTo(slots)
 	}
+}
 
-	// Calculate group and byte index
-	groupIdx := idx / groupSize
-	byteIdx := idx % groupSize
+// ReserveFromHistogram grows the table so that it can hold the 99th
+// percentile of the given expected sizes without resizing. Larger outliers
+// still cause regular growth, which bounds the memory spent on rare cases.
+// The table never shrinks here.
+func (st *SwissTable) ReserveFromHistogram(sizes []int) {
+	if len(sizes) == 0 {
+		return
+	}
+	sorted := append([]int(nil), sizes...)
+	sort.Ints(sorted)
+	rank := int(math.Ceil(histogramPercentile*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
 
-	// Clear metadata and entry
-	st.metadata[groupIdx].bytes[byteIdx] = 0
-	st.entries[idx] = entry{}
-	st.size--
-	return true
+	st.growFor(sorted[rank])
 }
 
-// Size returns the number of elements in the table
-func (st *SwissTable) Size() int {
-	return st.size
-}
+// Repair restores consistency between the control bytes, the entries and the
+// size counter, treating the entries as the source of truth: a full control
+// byte without an entry is cleared, an entry without a matching control byte is
+// kept, and the size and group occupancy are recounted. If anything was wrong
+// the table is also rehashed from its entries, unless its capacity is frozen.
+// Entries with a nil key count as empty. It returns the number of
+// inconsistencies fixed.
+func (st *SwissTable) Repair() int {
+	st.checkMutable()
+	repairs := 0
+	live := 0
+	for idx := range st.entries {
+		groupIdx := idx / groupSize
+		byteIdx := idx % groupSize
+		ctrl := st.metadata[groupIdx].bytes[byteIdx]
+		e := &st.entries[idx]
 
-// Visualize returns a pretty-printed string representation of the table
-func (st *SwissTable) Visualize() string {
-	var result strings.Builder
+		switch {
+		case e.key == nil && isFull(ctrl):
+			st.metadata[groupIdx].bytes[byteIdx] = ctrlEmpty
+			*e = entry{}
+			repairs++
+		case e.key != nil && ctrl != e.h2Hash:
+			// Any full byte marks the slot live, resize recomputes the H2
+			st.metadata[groupIdx].bytes[byteIdx] = e.h2Hash & h2Mask
+			repairs++
+		}
+		if e.key != nil {
+			live++
+		}
+	}
+	if live != st.size {
+		repairs++
+	}
+	// The control bytes above were written directly, not through setCtrl
+	st.size = live
+	st.recountOccupancy()
 
-	// Print header
-	result.WriteString("Swiss Table State\n")
-	result.WriteString(strings.Repeat("=", 50) + "\n")
+	if repairs > 0 {
+		// The rehash recomputes every H2 from its key. It fails under
+		// FreezeCapacity, or if a partition cannot hold its keys, and then the
+		// repaired layout stays, whose control bytes, size and occupancy
+		// already agree with the entries.
+		_ = st.resizeTo(len(st.entries))
+	}
+	return repairs
+}
 
-	// Print summary
-	fmt.Fprintf(&result, "Size: %d\n", st.size)
-	fmt.Fprintf(&result, "Groups: %d\n", st.groupCount)
-	fmt.Fprintf(&result, "Total Slots: %d\n", len(st.entries))
-	result.WriteString(strings.Repeat("-", 50) + "\n\n")
+// EnableAdaptiveLoadFactor makes the table lower its load factor threshold
+// when inserts start probing too many groups, so that badly distributed keys
+// trigger resizes earlier than the nominal 0.75 load factor would
+func (st *SwissTable) EnableAdaptiveLoadFactor() {
+	st.adaptive = true
+}
 
-	// Print metadata groups
-	result.WriteString("Metadata Groups (h2 hashes, 0 = empty):\n")
-	for i := 0; i < st.groupCount; i++ {
-		fmt.Fprintf(&result, "Group %2d: [", i)
-		for j := 0; j < groupSize; j++ {
-			if j > 0 {
-				result.WriteString("|")
-			}
-			h2 := st.metadata[i].bytes[j]
-			if h2 == 0 {
-				fmt.Fprintf(&result, "%3s", "·")
-			} else {
-				fmt.Fprintf(&result, "%3d", h2)
-			}
-		}
-		result.WriteString(" ]\n")
+// observeProbe records the probe length of an insert and lowers the load
+// factor once a full window averages above the threshold
+func (st *SwissTable) observeProbe(h1 uint64, idx int) {
+	st.probeSum += st.probeDistance(h1, idx) + 1
+	st.probeCount++
+	if st.probeCount < adaptiveWindow {
+		return
 	}
 
-	// Print entries
-	result.WriteString("\nEntries:\n")
-	result.WriteString("Index |  H2  | Key:Value\n")
-	result.WriteString(strings.Repeat("-", 50) + "\n")
-
-	for i := 0; i < len(st.entries); i++ {
-		entry := st.entries[i]
-		if entry.key != nil {
-			fmt.Fprintf(&result, "%4d  | %4d | %v:%v\n", 
-				i, entry.h2Hash, entry.key, entry.value)
+	avg := float64(st.probeSum) / float64(st.probeCount)
+	if avg > adaptiveProbeThreshold && st.maxLoad > adaptiveMinLoad {
+		st.maxLoad -= adaptiveStep
+		if st.maxLoad < adaptiveMinLoad {
+			st.maxLoad = adaptiveMinLoad
 		}
 	}
+	st.probeSum = 0
+	st.probeCount = 0
+}
 
-	return result.String()
+// Put inserts or updates a key-value pair. It panics if the pair is rejected
+// by a validator; use PutErr to handle rejections.
+func (st *SwissTable) Put(key, value any) {
+	st.Swap(key, value)
+}
+
+// Swap is Put that also returns the value it replaced, and whether the key
+// was present. It panics if the pair is rejected by a validator.
+func (st *SwissTable) Swap(key, value any) (old any, loaded bool) {
+	if st.instrumented {
+		defer observeSince(&st.latencies.Put, time.Now())
+	}
+	if err := st.checkPut(key, value); err != nil {
+		panic(err)
+	}
+	h1, h2 := st.hashKey(key)
+	if err := st.checkGrowth(key, h1, h2); err != nil {
+		panic(err)
+	}
+	_, old, loaded = st.putHashed(key, value, h1, h2)
+	return old, loaded
+}
+
+// PutErr inserts or updates a key-value pair, returning the validator's error
+// instead of inserting when the pair is rejected
+func (st *SwissTable) PutErr(key, value any) error {
+	if err := st.checkPut(key, value); err != nil {
+		return err
+	}
+	h1, h2 := st.hashKey(key)
+	if err := st.checkGrowth(key, h1, h2); err != nil {
+		return err
+	}
+	st.putHashed(key, value, h1, h2)
+	return nil
+}
+
+// LoadOrStore returns the existing value for key if present, without
+// overwriting it. Otherwise it stores value, growing the table if needed, and
+// returns it with loaded false. The key is hashed once either way. Like Put,
+// it panics if a new pair is rejected by a validator.
+func (st *SwissTable) LoadOrStore(key, value any) (actual any, loaded bool) {
+	h1, h2 := st.hashKey(key)
+	if idx, found := st.lookupHashed(key, h1, h2, 0); found {
+		return st.entries[idx].value, true
+	}
+	if err := st.checkPut(key, value); err != nil {
+		panic(err)
+	}
+	if err := st.checkGrowth(key, h1, h2); err != nil {
+		panic(err)
+	}
+	st.putHashed(key, value, h1, h2)
+	return value, false
+}
+
+// FreezeCapacity locks the current capacity until UnfreezeCapacity, so no
+// operation resizes and allocates a new slot array in between. An insert
+// that would need to grow fails instead: PutErr returns ErrCapacityFrozen and
+// Put panics with it. Updates of existing keys and deletes still work.
+func (st *SwissTable) FreezeCapacity() {
+	st.capacityFrozen = true
+}
+
+// UnfreezeCapacity lets the table grow again after FreezeCapacity
+func (st *SwissTable) UnfreezeCapacity() {
+	st.capacityFrozen = false
+}
+
+// checkGrowth returns ErrCapacityFrozen if inserting key would resize a table
+// whose capacity is frozen
+func (st *SwissTable) checkGrowth(key any, h1 uint64, h2 uint8) error {
+	if !st.capacityFrozen {
+		return nil
+	}
+	if _, found := st.lookupHashed(key, h1, h2, 0); found {
+		return nil
+	}
+	if float64(st.size+1)/float64(len(st.entries)) > st.maxLoad || st.firstFree(h1) == -1 {
+		return ErrCapacityFrozen
+	}
+	return nil
+}
+
+// SetKeyValidator installs a check that every key must pass before it is
+// inserted. Passing nil removes it.
+func (st *SwissTable) SetKeyValidator(fn func(key any) error) {
+	st.keyValidator = fn
+}
+
+// SetMaxValueSize rejects values whose size, as reported by sizeOf, exceeds
+// the given number of bytes. A cap of zero or less removes the limit.
+func (st *SwissTable) SetMaxValueSize(bytes int, sizeOf func(value any) int) {
+	st.maxValueSize = bytes
+	st.valueSizeOf = sizeOf
+}
+
+// checkPut runs the configured validators against a pair about to be inserted
+func (st *SwissTable) checkPut(key, value any) error {
+	if st.keyValidator != nil {
+		if err := st.keyValidator(key); err != nil {
+			return err
+		}
+	}
+	if st.maxValueSize > 0 {
+		if size := st.valueSizeOf(value); size > st.maxValueSize {
+			return fmt.Errorf("%w: %d bytes exceeds the %d byte cap", ErrValueTooLarge, size, st.maxValueSize)
+		}
+	}
+	return nil
+}
+
+// putHashed is Put for a key whose hashes are already known. It returns the
+// slot the entry was written to.
+func (st *SwissTable) putHashed(key, value any, h1 uint64, h2 uint8) (idx int, old any, loaded bool) {
+	st.checkMutable()
+	if st.interned != nil {
+		key = st.internKey(key)
+	}
+
+	// Check if we need to resize
+	if float64(st.size+st.tombstones+1)/float64(len(st.entries)) > st.maxLoad {
+		// A failed resize keeps the old layout, which still has free slots
+		// because the load factor is below one
+		_ = st.makeRoom()
+	}
+
+	idx, found := st.findSlotHashed(key, h1, h2)
+	for idx == -1 {
+		// Only a partitioned table can fill one partition below the load
+		// factor, growing gives every partition more room
+		if err := st.resize(); err != nil {
+			panic("table is full")
+		}
+		idx, found = st.findSlotHashed(key, h1, h2)
+	}
+
+	if found {
+		old = st.entries[idx].value
+	} else {
+		st.size++
+		if st.robinHood {
+			idx = st.robinHoodSlot(h1)
+		}
+	}
+
+	if st.adaptive && !found {
+		st.observeProbe(h1, idx)
+	}
+	if st.hll != nil {
+		st.observeDistinct(h1)
+	}
+
+	if st.dedup != nil {
+		value = st.dedup.acquire(value)
+		if found {
+			st.dedup.release(st.entries[idx].value)
+		}
+	}
+
+	// A new value supersedes a soft-deleted one
+	if st.softDeleted != nil && st.softDeleted.size > 0 {
+		if sdIdx, ok := st.softDeleted.findSlotHashed(key, h1, h2); ok {
+			st.softDeleted.deleteAt(sdIdx)
+		}
+	}
+
+	// Update entry and metadata
+	st.generation++
+	st.entries[idx] = entry{key: key, value: value, h2Hash: h2, gen: st.generation, fp: fingerprint(h1)}
+	st.setCtrl(idx, h2)
+	return idx, old, found
+}
+
+// Generation returns a marker that increases with every Put. Pass it to
+// RangeSince later to visit only the entries written after this point.
+func (st *SwissTable) Generation() uint64 {
+	return st.generation
+}
+
+// RangeSince calls fn for every live entry inserted or updated after the
+// given generation, stopping early if fn returns false. Like Range, it keeps
+// walking the slots it started with if fn modifies the table.
+func (st *SwissTable) RangeSince(gen uint64, fn func(key, value any) bool) {
+	entries, metadata := st.entries, st.metadata
+	for groupIdx, group := range metadata {
+		for byteIdx, h2 := range group.bytes {
+			if !isFull(h2) {
+				continue
+			}
+			e := entries[groupIdx*groupSize+byteIdx]
+			if e.gen > gen && !fn(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// EnableRobinHood switches inserts to Robin Hood placement: a new key takes
+// the slot of any entry that sits closer to its home group than the new key
+// would, and the displaced entry continues probing. This evens out probe
+// lengths and lowers the worst case at the cost of slower inserts. Lookups
+// are unaffected.
+func (st *SwissTable) EnableRobinHood() {
+	st.robinHood = true
+}
+
+// probeDistance returns how many groups past its home group slot idx is for
+// a key with the given H1
+func (st *SwissTable) probeDistance(h1 uint64, idx int) int {
+	home := int(st.homeGroup(h1))
+	span := st.probeSpan()
+	return (idx/groupSize - home + span) % span
+}
+
+// robinHoodSlot frees a slot for a new key with the given H1 by displacing
+// entries that are closer to home, and returns the slot for the new key. The
+// table must have at least one free slot.
+func (st *SwissTable) robinHoodSlot(h1 uint64) int {
+	target := -1
+	var carry entry
+	home := st.homeGroup(h1)
+	span := st.probeSpan()
+
+	for groupIdx := home; ; groupIdx = st.nextGroup(groupIdx) {
+		base := int(groupIdx) * groupSize
+
+		if empty := matchEmptyOrDeleted(&st.metadata[groupIdx]); empty != 0 {
+			slot := base + bits.TrailingZeros16(empty)
+			if target == -1 {
+				return slot
+			}
+			st.entries[slot] = carry
+			st.setCtrl(slot, carry.h2Hash)
+			return target
+		}
+
+		// Pick the occupant closest to its home, if it is richer than us
+		dist := (int(groupIdx) - int(home) + span) % span
+		best, bestDist, bestHome := -1, dist, uint64(0)
+		for pos := 0; pos < groupSize; pos++ {
+			occH1, _ := st.hashKey(st.entries[base+pos].key)
+			if d := st.probeDistance(occH1, base+pos); d < bestDist {
+				best, bestDist, bestHome = base+pos, d, st.homeGroup(occH1)
+			}
+		}
+		if best == -1 {
+			continue
+		}
+
+		if target == -1 {
+			// The new key takes this slot, the caller writes it
+			target = best
+			carry = st.entries[best]
+		} else {
+			carry, st.entries[best] = st.entries[best], carry
+			st.setCtrl(best, st.entries[best].h2Hash)
+		}
+		home = bestHome
+	}
+}
+
+// PutMeta inserts or updates a key-value pair together with arbitrary
+// metadata that is stored alongside the entry. A plain Put replaces the entry
+// and drops any metadata previously attached to the key. Like Put, it panics
+// if the pair is rejected by a validator.
+func (st *SwissTable) PutMeta(key, value, meta any) {
+	if err := st.checkPut(key, value); err != nil {
+		panic(err)
+	}
+	h1, h2 := st.hashKey(key)
+	if err := st.checkGrowth(key, h1, h2); err != nil {
+		panic(err)
+	}
+	idx, _, _ := st.putHashed(key, value, h1, h2)
+	st.entries[idx].meta = meta
+}
+
+// GetMeta retrieves a value and the metadata attached by PutMeta
+func (st *SwissTable) GetMeta(key any) (value, meta any, ok bool) {
+	idx, found := st.findSlot(key)
+	if !found || idx == -1 {
+		return nil, nil, false
+	}
+	return st.entries[idx].value, st.entries[idx].meta, true
+}
+
+// EnableDistinctEstimate starts maintaining a HyperLogLog sketch of every key
+// passed to Put, including keys that are later deleted. Keys inserted before
+// the call are not counted. The sketch costs 16KiB.
+func (st *SwissTable) EnableDistinctEstimate() {
+	if st.hll == nil {
+		st.hll = make([]uint8, 1<<hllPrecision)
+	}
+}
+
+// observeDistinct folds a key's H1 hash into the HyperLogLog registers
+func (st *SwissTable) observeDistinct(h1 uint64) {
+	// H1 holds the top 64-h2Bits bits of the hash, shift them back into place
+	x := h1 << h2Bits
+	reg := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > st.hll[reg] {
+		st.hll[reg] = rank
+	}
+}
+
+// ApproxDistinctKeys estimates how many distinct keys have ever been Put
+// since EnableDistinctEstimate, with a typical error below 1%. It returns 0
+// when the estimate is not enabled.
+func (st *SwissTable) ApproxDistinctKeys() uint64 {
+	if st.hll == nil {
+		return 0
+	}
+
+	m := float64(len(st.hll))
+	sum := 0.0
+	zeros := 0
+	for _, r := range st.hll {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// PutColumns inserts keys[i] -> values[i] for every i, growing the table once
+// up front. It fails without inserting anything if the slices differ in
+// length, and stops at the first pair a validator rejects, leaving the
+// earlier pairs inserted.
+func (st *SwissTable) PutColumns(keys, values []any) error {
+	if len(keys) != len(values) {
+		return fmt.Errorf("swisstable: %d keys but %d values", len(keys), len(values))
+	}
+	st.growFor(st.size + len(keys))
+	for i, key := range keys {
+		if err := st.PutErr(key, values[i]); err != nil {
+			return fmt.Errorf("swisstable: pair %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// PutMany inserts every pair of m, growing the table at most once up front.
+// Like Put, it panics if a validator rejects a pair.
+func (st *SwissTable) PutMany(m map[any]any) {
+	st.Reserve(len(m))
+	for key, value := range m {
+		st.Put(key, value)
+	}
+}
+
+// FromMap creates a table holding every pair of m, sized for them up front
+func FromMap(m map[any]any) *SwissTable {
+	st := NewWithCapacity(len(m))
+	st.PutMany(m)
+	return st
+}
+
+// ToMap copies the live entries into a new map, which is empty rather than
+// nil for an empty table. It panics on a key Go maps cannot hold, such as a
+// slice stored under a custom equality.
+func (st *SwissTable) ToMap() map[any]any {
+	m := make(map[any]any, st.size)
+	st.Range(func(key, value any) bool {
+		m[key] = value
+		return true
+	})
+	return m
+}
+
+// BuildParallel creates a table from key-value pairs, hashing the keys across
+// the given number of goroutines before inserting them serially. Hashing
+// dominates the cost of large builds and needs no coordination, so it
+// parallelizes cleanly. Later pairs win when keys repeat, as with Put.
+func BuildParallel(pairs [][2]any, workers int) *SwissTable {
+	st := New()
+	if workers < 1 {
+		workers = 1
+	}
+	// A fresh table has no entries to rehash, so presizing cannot fail
+	_ = st.resizeTo(st.capacityFor(len(pairs)))
+
+	type hashes struct {
+		h1 uint64
+		h2 uint8
+	}
+	hashed := make([]hashes, len(pairs))
+
+	var wg sync.WaitGroup
+	chunk := (len(pairs) + workers - 1) / workers
+	for start := 0; start < len(pairs); start += chunk {
+		end := min(start+chunk, len(pairs))
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				hashed[i].h1, hashed[i].h2 = st.hashKey(pairs[i][0])
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	for i, pair := range pairs {
+		st.putHashed(pair[0], pair[1], hashed[i].h1, hashed[i].h2)
+	}
+	return st
+}
+
+// Get retrieves a value by key
+func (st *SwissTable) Get(key any) (any, bool) {
+	if st.instrumented {
+		defer observeSince(&st.latencies.Get, time.Now())
+	}
+	_, idx, found := st.lookup(key, st.maxLookupProbes)
+	if !found {
+		return nil, false
+	}
+	return st.entries[idx].value, true
+}
+
+// GetBytes retrieves a []byte value without copying it; the returned slice
+// shares the stored backing array. It returns false if the key is absent or
+// its value is not a byte slice.
+func (st *SwissTable) GetBytes(key any) ([]byte, bool) {
+	v, ok := st.Get(key)
+	if !ok {
+		return nil, false
+	}
+	b, ok := v.([]byte)
+	return b, ok
+}
+
+// Rename moves the value stored under oldKey to newKey, along with any
+// metadata. It returns false and changes nothing if oldKey is absent, newKey
+// is already present, or newKey is rejected by a validator.
+func (st *SwissTable) Rename(oldKey, newKey any) bool {
+	idx, found := st.findSlot(oldKey)
+	if !found || idx == -1 {
+		return false
+	}
+	// Read the entry first, looking up newKey may reseed and move it
+	moved := st.entries[idx]
+	if _, taken := st.findSlot(newKey); taken {
+		return false
+	}
+	if st.checkPut(newKey, moved.value) != nil {
+		return false
+	}
+
+	st.Delete(oldKey)
+	h1, h2 := st.hashKey(newKey)
+	newIdx, _, _ := st.putHashed(newKey, moved.value, h1, h2)
+	st.entries[newIdx].meta = moved.meta
+	return true
+}
+
+// Delete removes a key-value pair
+func (st *SwissTable) Delete(key any) bool {
+	if st.instrumented {
+		defer observeSince(&st.latencies.Delete, time.Now())
+	}
+	st.checkMutable()
+	idx, found := st.findSlot(key)
+	if !found || idx == -1 {
+		return false
+	}
+	st.deleteAt(idx)
+	return true
+}
+
+// GetAndDelete removes key and returns the value it held, finding the slot
+// with a single probe. loaded is false if the key was absent.
+func (st *SwissTable) GetAndDelete(key any) (value any, loaded bool) {
+	st.checkMutable()
+	idx, found := st.findSlot(key)
+	if !found {
+		return nil, false
+	}
+	value = st.entries[idx].value
+	st.deleteAt(idx)
+	return value, true
+}
+
+// deleteAt removes the live entry in slot idx
+func (st *SwissTable) deleteAt(idx int) {
+	if st.dedup != nil {
+		st.dedup.release(st.entries[idx].value)
+	}
+
+	// A group that still has an empty slot ends every probe sequence reaching
+	// it, so no later key depends on this slot and it can become empty again.
+	// Otherwise leave a tombstone to keep the probe chain intact.
+	ctrl := uint8(ctrlDeleted)
+	if matchEmpty(&st.metadata[idx/groupSize]) != 0 {
+		ctrl = ctrlEmpty
+	}
+	st.setCtrl(idx, ctrl)
+	st.entries[idx] = entry{}
+	st.size--
+}
+
+// UpdateAll replaces the value of every live entry with fn's result, in
+// place: keys, slots and metadata are unchanged and nothing is rehashed. Each
+// updated entry counts as written for RangeSince. Like Put, it panics if a new
+// value is rejected by a validator, leaving earlier entries updated.
+func (st *SwissTable) UpdateAll(fn func(key, value any) any) {
+	st.checkMutable()
+	for idx := range st.entries {
+		if !isFull(st.metadata[idx/groupSize].bytes[idx%groupSize]) {
+			continue
+		}
+		e := &st.entries[idx]
+		value := fn(e.key, e.value)
+		if err := st.checkPut(e.key, value); err != nil {
+			panic(err)
+		}
+		if st.dedup != nil {
+			value = st.dedup.acquire(value)
+			st.dedup.release(e.value)
+		}
+		st.generation++
+		e.value = value
+		e.gen = st.generation
+	}
+}
+
+// MapValues is UpdateAll: it replaces every live value with f(key, value) in
+// place, keeping keys, slots and size
+func (st *SwissTable) MapValues(f func(key, value any) any) {
+	st.UpdateAll(f)
+}
+
+// SoftDelete removes key from the table like Delete but keeps its value,
+// which GetDeleted still returns until the key is put again, hard deleted or
+// purged. It returns false if the key is absent.
+func (st *SwissTable) SoftDelete(key any) bool {
+	st.checkMutable()
+	h1, h2 := st.hashKey(key)
+	idx, found := st.findSlotHashed(key, h1, h2)
+	if !found {
+		return false
+	}
+	e := st.entries[idx]
+	st.deleteAt(idx)
+
+	if st.softDeleted == nil {
+		st.softDeleted = st.newLike()
+	}
+	idx, _, _ = st.softDeleted.putHashed(e.key, e.value, h1, h2)
+	st.softDeleted.entries[idx] = e
+	return true
+}
+
+// GetDeleted looks key up among both live and soft-deleted entries. deleted
+// reports whether the value returned was soft deleted.
+func (st *SwissTable) GetDeleted(key any) (value any, deleted bool, ok bool) {
+	h1, h2 := st.hashKey(key)
+	if idx, found := st.findSlotHashed(key, h1, h2); found {
+		return st.entries[idx].value, false, true
+	}
+	if st.softDeleted != nil {
+		if idx, found := st.softDeleted.findSlotHashed(key, h1, h2); found {
+			return st.softDeleted.entries[idx].value, true, true
+		}
+	}
+	return nil, false, false
+}
+
+// HardDelete removes key whether it is live or soft deleted, reporting
+// whether anything was removed
+func (st *SwissTable) HardDelete(key any) bool {
+	removed := st.Delete(key)
+	if st.softDeleted != nil && st.softDeleted.Delete(key) {
+		removed = true
+	}
+	return removed
+}
+
+// PurgeSoftDeleted drops every soft-deleted entry and returns how many there were
+func (st *SwissTable) PurgeSoftDeleted() int {
+	st.checkMutable()
+	if st.softDeleted == nil {
+		return 0
+	}
+	n := st.softDeleted.size
+	st.softDeleted = nil
+	return n
+}
+
+// Clear removes every entry, including soft-deleted ones, while keeping the
+// current capacity and backing arrays for reuse. Entries are zeroed so their
+// keys and values can be collected. Lifetime statistics such as the
+// generation and the distinct-key estimate are kept.
+func (st *SwissTable) Clear() {
+	st.checkMutable()
+	for i := range st.metadata {
+		for j := range st.metadata[i].bytes {
+			st.metadata[i].bytes[j] = ctrlEmpty
+		}
+	}
+	clear(st.entries)
+	clear(st.occupancy)
+	st.size = 0
+	st.tombstones = 0
+	st.softDeleted = nil
+	if st.dedup != nil {
+		clear(st.dedup.values)
+	}
+	st.probeSum = 0
+	st.probeCount = 0
+	st.epoch++
+}
+
+// DrainIf removes every entry for which pred returns true and returns the
+// removed entries as key-value pairs in slot order
+func (st *SwissTable) DrainIf(pred func(key, value any) bool) [][2]any {
+	st.checkMutable()
+	var drained [][2]any
+	for idx, e := range st.entries {
+		if !isFull(st.metadata[idx/groupSize].bytes[idx%groupSize]) {
+			continue
+		}
+		if pred(e.key, e.value) {
+			drained = append(drained, [2]any{e.key, e.value})
+			st.deleteAt(idx)
+		}
+	}
+	return drained
+}
+
+// DeleteFunc removes every entry for which pred returns true in a single pass
+// over the slots and returns the number removed
+func (st *SwissTable) DeleteFunc(pred func(key, value any) bool) int {
+	st.checkMutable()
+	removed := 0
+	for idx, e := range st.entries {
+		if !isFull(st.metadata[idx/groupSize].bytes[idx%groupSize]) {
+			continue
+		}
+		if pred(e.key, e.value) {
+			st.deleteAt(idx)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Clone returns an independent deep copy of the table. The hash seed and
+// every setting are kept, so the copy has the same slot layout and nothing is
+// rehashed. Keys and values themselves are shared, not copied. The clone of a
+// frozen table is not frozen.
+func (st *SwissTable) Clone() *SwissTable {
+	c := *st
+	c.entries = slices.Clone(st.entries)
+	c.metadata = slices.Clone(st.metadata)
+	c.occupancy = slices.Clone(st.occupancy)
+	c.hll = slices.Clone(st.hll)
+	c.interned = maps.Clone(st.interned)
+	if st.softDeleted != nil {
+		c.softDeleted = st.softDeleted.Clone()
+	}
+	if st.dedup != nil {
+		c.dedup = st.dedup.clone()
+	}
+	c.frozen = false
+	return &c
+}
+
+// Split partitions the live entries round-robin into n new tables whose sizes
+// differ by at most one. The original table is left intact. It returns nil
+// when n is less than 1.
+func (st *SwissTable) Split(n int) []*SwissTable {
+	if n < 1 {
+		return nil
+	}
+	parts := make([]*SwissTable, n)
+	for i := range parts {
+		parts[i] = st.newLike()
+		// The parts are still empty, so presizing them cannot fail
+		_ = parts[i].resizeTo(parts[i].capacityFor((st.size + n - 1) / n))
+	}
+
+	next := 0
+	for groupIdx, group := range st.metadata {
+		for byteIdx, h2 := range group.bytes {
+			if isFull(h2) {
+				e := st.entries[groupIdx*groupSize+byteIdx]
+				parts[next].Put(e.key, e.value)
+				next = (next + 1) % n
+			}
+		}
+	}
+	return parts
+}
+
+// Merge puts every live entry of other into st, with other's value winning
+// when both hold a key. Keys are rehashed with st's hasher, so the tables may
+// use different seeds; st grows as needed.
+func (st *SwissTable) Merge(other *SwissTable) {
+	st.MergeFunc(other, func(k, oldV, newV any) any { return newV })
+}
+
+// MergeFunc is Merge with conflicts decided by resolve, which receives the
+// key, st's value and other's value and returns the value to keep
+func (st *SwissTable) MergeFunc(other *SwissTable, resolve func(k, oldV, newV any) any) {
+	other.Range(func(key, value any) bool {
+		if idx, found := st.findSlot(key); found {
+			value = resolve(key, st.entries[idx].value, value)
+		}
+		st.Put(key, value)
+		return true
+	})
+}
+
+// Conflict is a key that both tables passed to MergeWithLog hold with
+// different values
+type Conflict struct {
+	Key           any
+	ReceiverValue any
+	OtherValue    any
+}
+
+// MergeWithLog merges other into st like MergeFunc, keeping st's value when
+// both hold a key, and returns every such key whose values differ under
+// reflect.DeepEqual, in other's slot order
+func (st *SwissTable) MergeWithLog(other *SwissTable) []Conflict {
+	var conflicts []Conflict
+	st.MergeFunc(other, func(k, oldV, newV any) any {
+		if !reflect.DeepEqual(oldV, newV) {
+			conflicts = append(conflicts, Conflict{Key: k, ReceiverValue: oldV, OtherValue: newV})
+		}
+		return oldV
+	})
+	return conflicts
+}
+
+// MergeStream yields every distinct key across the given tables together with
+// its value, without building a combined table. When a key appears in more
+// than one table the value from the earliest table wins. Each candidate is
+// checked against the earlier tables, so modifying any of them while the
+// sequence is being consumed leaves the remaining pairs undefined, though it
+// never panics.
+func MergeStream(tables ...*SwissTable) iter.Seq2[any, any] {
+	return func(yield func(key, value any) bool) {
+		for i, st := range tables {
+			entries, metadata := st.entries, st.metadata
+			for idx, e := range entries {
+				if !isFull(metadata[idx/groupSize].bytes[idx%groupSize]) {
+					continue
+				}
+				if shadowedBy(tables[:i], e.key) {
+					continue
+				}
+				if !yield(e.key, e.value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// shadowedBy reports whether any of the tables holds key
+func shadowedBy(tables []*SwissTable, key any) bool {
+	for _, st := range tables {
+		if _, found := st.findSlot(key); found {
+			return true
+		}
+	}
+	return false
+}
+
+// Scan returns up to limit live entries as key-value pairs, starting at the
+// position given by cursor, together with the cursor for the next page.
+// Start with cursor 0; a returned cursor of 0 means the scan is complete.
+// A cursor holds a slot index and the table's epoch, which Clear, every
+// rehash and UnmarshalLayout advance. Passing a cursor from an earlier epoch
+// panics with ErrStaleCursor rather than skipping or repeating entries.
+// Deletes keep cursors valid, but any Put may rehash the table and so
+// invalidate them, as may a Get that reseeds under SetMaxComparisons. Use ScanErr when writes can interleave with a scan.
+func (st *SwissTable) Scan(cursor uint64, limit int) (entries [][2]any, next uint64) {
+	entries, next, err := st.ScanErr(cursor, limit)
+	if err != nil {
+		panic(err)
+	}
+	return entries, next
+}
+
+// ScanErr is Scan that returns ErrStaleCursor instead of panicking, so a
+// paginated caller can restart the scan from cursor 0 after a rehash
+func (st *SwissTable) ScanErr(cursor uint64, limit int) (entries [][2]any, next uint64, err error) {
+	if cursor != 0 && cursor>>cursorSlotBits != st.epoch&cursorSlotMask {
+		return nil, 0, ErrStaleCursor
+	}
+	if limit <= 0 {
+		return nil, cursor, nil
+	}
+	for idx := cursor & cursorSlotMask; idx < uint64(len(st.entries)); idx++ {
+		if !isFull(st.metadata[idx/groupSize].bytes[idx%groupSize]) {
+			continue
+		}
+		e := st.entries[idx]
+		entries = append(entries, [2]any{e.key, e.value})
+		if len(entries) == limit {
+			if idx+1 < uint64(len(st.entries)) {
+				return entries, st.epoch&cursorSlotMask<<cursorSlotBits | (idx + 1), nil
+			}
+			break
+		}
+	}
+	return entries, 0, nil
+}
+
+// Batches yields the live entries in slot order as key-value pairs, in
+// batches of size entries; only the last batch may be shorter. Each batch is
+// a new slice the caller may keep. Nothing is yielded if size is not
+// positive. As with Range, modifying the table mid-iteration leaves the
+// remaining batches undefined, but Batches never panics: it keeps walking the
+// slots it started with.
+func (st *SwissTable) Batches(size int) iter.Seq[[][2]any] {
+	return func(yield func([][2]any) bool) {
+		if size <= 0 {
+			return
+		}
+		entries, metadata := st.entries, st.metadata
+		batch := make([][2]any, 0, size)
+		for idx, e := range entries {
+			if !isFull(metadata[idx/groupSize].bytes[idx%groupSize]) {
+				continue
+			}
+			batch = append(batch, [2]any{e.key, e.value})
+			if len(batch) == size {
+				if !yield(batch) {
+					return
+				}
+				batch = make([][2]any, 0, size)
+			}
+		}
+		if len(batch) > 0 {
+			yield(batch)
+		}
+	}
+}
+
+// Range calls f for every live entry in slot order, skipping empty and
+// deleted slots, and stops early if f returns false. If f modifies the table
+// the set and order of the remaining visits is undefined, but Range never
+// panics: it keeps walking the slots it started with.
+func (st *SwissTable) Range(f func(key, value any) bool) {
+	entries, metadata := st.entries, st.metadata
+	for idx, e := range entries {
+		if !isFull(metadata[idx/groupSize].bytes[idx%groupSize]) {
+			continue
+		}
+		if !f(e.key, e.value) {
+			return
+		}
+	}
+}
+
+// Keys returns the live keys in slot order in a new slice, which is empty
+// rather than nil for an empty table
+func (st *SwissTable) Keys() []any {
+	keys := make([]any, 0, st.size)
+	st.Range(func(key, value any) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// Values returns the live values in slot order in a new slice. Without a
+// modification in between, Values()[i] belongs to Keys()[i].
+func (st *SwissTable) Values() []any {
+	values := make([]any, 0, st.size)
+	st.Range(func(key, value any) bool {
+		values = append(values, value)
+		return true
+	})
+	return values
+}
+
+// RangeReverse calls fn for every live entry from the highest slot down to
+// slot zero, stopping early if fn returns false. A modification made by fn
+// does not affect which slots are visited.
+func (st *SwissTable) RangeReverse(fn func(key, value any) bool) {
+	entries, metadata := st.entries, st.metadata
+	for idx := len(entries) - 1; idx >= 0; idx-- {
+		if !isFull(metadata[idx/groupSize].bytes[idx%groupSize]) {
+			continue
+		}
+		if !fn(entries[idx].key, entries[idx].value) {
+			return
+		}
+	}
+}
+
+// GroupBy partitions the values of all live entries by the bucket keyFn
+// derives from each entry. Values within a bucket are in slot order.
+func (st *SwissTable) GroupBy(keyFn func(key, value any) any) map[any][]any {
+	buckets := make(map[any][]any)
+	entries, metadata := st.entries, st.metadata
+	for idx, e := range entries {
+		if !isFull(metadata[idx/groupSize].bytes[idx%groupSize]) {
+			continue
+		}
+		bucket := keyFn(e.key, e.value)
+		buckets[bucket] = append(buckets[bucket], e.value)
+	}
+	return buckets
+}
+
+// Contains reports whether key is present
+func (st *SwissTable) Contains(key any) bool {
+	_, found := st.findSlot(key)
+	return found
+}
+
+// SlotOf returns the backing-array index holding key, and false if the key is
+// absent. The index is only valid until the next resize, which moves entries.
+func (st *SwissTable) SlotOf(key any) (int, bool) {
+	idx, found := st.findSlot(key)
+	if !found || idx == -1 {
+		return -1, false
+	}
+	return idx, true
+}
+
+// ControlByteOf returns the control byte of the slot holding key, which is
+// the key's 7-bit H2, and false if the key is absent
+func (st *SwissTable) ControlByteOf(key any) (ctrl uint8, ok bool) {
+	idx, found := st.findSlot(key)
+	if !found {
+		return 0, false
+	}
+	return st.metadata[idx/groupSize].bytes[idx%groupSize], true
+}
+
+// Size returns the number of elements in the table
+func (st *SwissTable) Size() int {
+	return st.size
+}
+
+// Cap returns the number of slots in the table
+func (st *SwissTable) Cap() int {
+	return len(st.entries)
+}
+
+// LoadFactor returns the fraction of slots holding a live entry. Put keeps it
+// at or below the resize threshold, 0.75 unless configured otherwise.
+func (st *SwissTable) LoadFactor() float64 {
+	return float64(st.size) / float64(len(st.entries))
+}
+
+// DetectStringificationCollisions groups live keys that print identically
+// with %v but are distinct under ==. When their type has no dedicated path in
+// the default hasher, such keys share a hash because it falls back to the
+// formatted string, so they always probe the same slots.
+func (st *SwissTable) DetectStringificationCollisions() [][]any {
+	byRepr := make(map[string][]any)
+	for groupIdx, group := range st.metadata {
+		for byteIdx, h2 := range group.bytes {
+			if isFull(h2) {
+				key := st.entries[groupIdx*groupSize+byteIdx].key
+				repr := fmt.Sprintf("%v", key)
+				byRepr[repr] = append(byRepr[repr], key)
+			}
+		}
+	}
+
+	reprs := make([]string, 0, len(byRepr))
+	for repr, keys := range byRepr {
+		if len(keys) > 1 {
+			reprs = append(reprs, repr)
+		}
+	}
+	sort.Strings(reprs)
+
+	collisions := make([][]any, 0, len(reprs))
+	for _, repr := range reprs {
+		collisions = append(collisions, byRepr[repr])
+	}
+	return collisions
+}
+
+// KeyTypeHistogram counts the live keys by Go type name, such as "int" or
+// "main.UserID", to spot a table that accidentally mixes key types. A nil key
+// is counted as "<nil>".
+func (st *SwissTable) KeyTypeHistogram() map[string]int {
+	counts := make(map[string]int)
+	st.Range(func(key, value any) bool {
+		counts[fmt.Sprintf("%T", key)]++
+		return true
+	})
+	return counts
+}
+
+// ExportMetadata returns a copy of every control-byte group, for tools that
+// analyze occupancy and fingerprint distribution without seeing the entries
+func (st *SwissTable) ExportMetadata() [][groupSize]uint8 {
+	groups := make([][groupSize]uint8, len(st.metadata))
+	for i, group := range st.metadata {
+		groups[i] = group.bytes
+	}
+	return groups
+}
+
+// FingerprintCollisionRate returns the fraction of live entries whose H2
+// fingerprint is shared by another entry in the same group. A high rate means
+// matchGroup reports many false candidates and lookups do extra compares.
+func (st *SwissTable) FingerprintCollisionRate() float64 {
+	if st.size == 0 {
+		return 0
+	}
+	shared := 0
+	for _, group := range st.metadata {
+		var counts [h2Mask + 1]int
+		for _, h2 := range group.bytes {
+			if isFull(h2) {
+				counts[h2]++
+			}
+		}
+		for h2 := 0; h2 <= h2Mask; h2++ {
+			if counts[h2] > 1 {
+				shared += counts[h2]
+			}
+		}
+	}
+	return float64(shared) / float64(st.size)
+}
+
+// probeStats returns the average and maximum number of groups probed to
+// reach each live entry from its home group
+func (st *SwissTable) probeStats() (avg float64, longest int) {
+	if st.size == 0 {
+		return 0, 0
+	}
+	total := 0
+	for idx, e := range st.entries {
+		if !isFull(st.metadata[idx/groupSize].bytes[idx%groupSize]) {
+			continue
+		}
+		h1, _ := st.hashKey(e.key)
+		probes := st.probeDistance(h1, idx) + 1
+		total += probes
+		longest = max(longest, probes)
+	}
+	return float64(total) / float64(st.size), longest
+}
+
+// QuickStats returns the size, slot capacity and current load without
+// allocating, for high-frequency polling
+func (st *SwissTable) QuickStats() (size, cap int, load float64) {
+	return st.size, len(st.entries), float64(st.size) / float64(len(st.entries))
+}
+
+// Stats describes a table's occupancy and probe behavior, as returned by
+// SwissTable.Stats
+type Stats struct {
+	Size       int
+	Capacity   int
+	Groups     int
+	Tombstones int
+	LoadFactor float64
+	// Groups probed to reach each live key, counting its home group
+	AverageProbeLength float64
+	MaxProbeLength     int
+}
+
+// Stats walks every slot and every live key's probe sequence to summarize
+// the table. It takes time linear in the capacity and is meant for
+// diagnosing slow lookups; QuickStats is the cheap alternative.
+func (st *SwissTable) Stats() Stats {
+	s := Stats{
+		Size:       st.size,
+		Capacity:   len(st.entries),
+		Groups:     st.groupCount,
+		LoadFactor: st.LoadFactor(),
+	}
+	for i := range st.metadata {
+		for _, ctrl := range st.metadata[i].bytes {
+			if ctrl == ctrlDeleted {
+				s.Tombstones++
+			}
+		}
+	}
+	s.AverageProbeLength, s.MaxProbeLength = st.probeStats()
+	return s
+}
+
+// Visualize returns a pretty-printed string representation of the table
+func (st *SwissTable) Visualize() string {
+	return st.VisualizeN(math.MaxInt)
+}
+
+// VisualizeN is Visualize with at most maxRows metadata group rows and at most
+// maxRows entry rows, each followed by a note of how many rows were left out,
+// so the output stays bounded on large tables
+func (st *SwissTable) VisualizeN(maxRows int) string {
+	maxRows = max(maxRows, 0)
+	var result strings.Builder
+
+	// Print header
+	result.WriteString("Swiss Table State\n")
+	result.WriteString(strings.Repeat("=", 50) + "\n")
+
+	// Print summary
+	fmt.Fprintf(&result, "Size: %d\n", st.size)
+	fmt.Fprintf(&result, "Groups: %d\n", st.groupCount)
+	fmt.Fprintf(&result, "Total Slots: %d\n", len(st.entries))
+	result.WriteString(strings.Repeat("-", 50) + "\n\n")
+
+	// Print metadata groups
+	result.WriteString("Metadata Groups (h2 hashes, · = empty, x = deleted):\n")
+	for i := 0; i < min(st.groupCount, maxRows); i++ {
+		fmt.Fprintf(&result, "Group %2d: [", i)
+		for j := 0; j < groupSize; j++ {
+			if j > 0 {
+				result.WriteString("|")
+			}
+			h2 := st.metadata[i].bytes[j]
+			if h2 == ctrlEmpty {
+				fmt.Fprintf(&result, "%3s", "·")
+			} else if h2 == ctrlDeleted {
+				fmt.Fprintf(&result, "%3s", "x")
+			} else {
+				fmt.Fprintf(&result, "%3d", h2)
+			}
+		}
+		result.WriteString(" ]\n")
+	}
+	if omitted := st.groupCount - maxRows; omitted > 0 {
+		fmt.Fprintf(&result, "(%d more groups omitted)\n", omitted)
+	}
+
+	// Print entries
+	result.WriteString("\nEntries:\n")
+	result.WriteString("Index |  H2  | Key:Value\n")
+	result.WriteString(strings.Repeat("-", 50) + "\n")
+
+	rows := 0
+	for i := 0; i < len(st.entries); i++ {
+		entry := st.entries[i]
+		if entry.key != nil {
+			if rows == maxRows {
+				fmt.Fprintf(&result, "(%d more entries omitted)\n", st.size-rows)
+				break
+			}
+			fmt.Fprintf(&result, "%4d  | %4d | %v:%v\n", 
+				i, entry.h2Hash, entry.key, entry.value)
+			rows++
+		}
+	}
+
+	return result.String()
+}
+
+// ParseVisualize rebuilds a table from the "Entries" section of Visualize output.
+// Keys and values that parse as integers become ints, everything else is kept
+// as a string. Keys containing ':' or '|' cannot be recovered unambiguously.
+// The layout of the returned table may differ from the dumped one because a
+// fresh hash seed is used. Rows left out by VisualizeN are not recovered.
+func ParseVisualize(s string) (*SwissTable, error) {
+	st := New()
+
+	lines := strings.Split(s, "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "Entries:" {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return nil, fmt.Errorf("swisstable: missing Entries section")
+	}
+
+	// Skip the column header and separator rows
+	for lineNo := start + 3; lineNo < len(lines); lineNo++ {
+		line := lines[lineNo]
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "(") {
+			// Blank, or the omission note VisualizeN writes
+			continue
+		}
+		cols := strings.SplitN(line, "|", 3)
+		if len(cols) != 3 {
+			return nil, fmt.Errorf("swisstable: line %d: malformed entry row %q", lineNo+1, line)
+		}
+		pair := strings.TrimPrefix(cols[2], " ")
+		sep := strings.Index(pair, ":")
+		if sep == -1 {
+			return nil, fmt.Errorf("swisstable: line %d: missing key:value separator", lineNo+1)
+		}
+		st.Put(parseVisualizeToken(pair[:sep]), parseVisualizeToken(pair[sep+1:]))
+	}
+
+	return st, nil
+}
+
+// ReplayLog applies a recorded sequence of operations to the table. Each op
+// is one of
+//
+//	Put(key,value)
+//	Get(key)
+//	Delete(key)
+//
+// optionally followed by " = result" as written by the test harness, which is
+// ignored. Tokens that parse as integers become ints and everything else is
+// used as a string, so keys must not contain commas or parentheses. Spaces
+// around arguments are ignored. Replay stops at the first malformed op.
+func (st *SwissTable) ReplayLog(ops []string) error {
+	for i, op := range ops {
+		if eq := strings.Index(op, " = "); eq != -1 {
+			op = op[:eq]
+		}
+		op = strings.TrimSpace(op)
+
+		open := strings.Index(op, "(")
+		if open == -1 || !strings.HasSuffix(op, ")") {
+			return fmt.Errorf("swisstable: op %d: malformed %q", i+1, ops[i])
+		}
+		name, args := op[:open], op[open+1:len(op)-1]
+
+		switch name {
+		case "Put":
+			comma := strings.Index(args, ",")
+			if comma == -1 {
+				return fmt.Errorf("swisstable: op %d: Put needs key and value", i+1)
+			}
+			key := strings.TrimSpace(args[:comma])
+			value := strings.TrimSpace(args[comma+1:])
+			st.Put(parseVisualizeToken(key), parseVisualizeToken(value))
+		case "Get":
+			st.Get(parseVisualizeToken(strings.TrimSpace(args)))
+		case "Delete":
+			st.Delete(parseVisualizeToken(strings.TrimSpace(args)))
+		default:
+			return fmt.Errorf("swisstable: op %d: unknown operation %q", i+1, name)
+		}
+	}
+	return nil
+}
+
+// parseVisualizeToken converts a %v-formatted token back into a value
+func parseVisualizeToken(tok string) any {
+	if n, err := strconv.Atoi(tok); err == nil {
+		return n
+	}
+	return tok
 }
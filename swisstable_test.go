@@ -1,10 +1,18 @@
 package swisstable
 
 import (
+	"errors"
 	"fmt"
+	"hash/maphash"
+	"maps"
+	"math"
+	"math/bits"
 	"math/rand"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"unsafe"
 )
 
 type operation int
@@ -33,27 +41,2625 @@ func TestVisualize(t *testing.T) {
 	fmt.Printf("\nTable after deleting 2:\n%s\n", st.Visualize())
 }
 
+func TestParseVisualize(t *testing.T) {
+	st := New()
+	for i := 0; i < 40; i++ {
+		st.Put(i, fmt.Sprintf("v%d", i))
+	}
+	st.Delete(7)
+
+	parsed, err := ParseVisualize(st.Visualize())
+	if err != nil {
+		t.Fatalf("ParseVisualize failed: %v", err)
+	}
+	if parsed.Size() != st.Size() {
+		t.Errorf("Expected size %d, got %d", st.Size(), parsed.Size())
+	}
+	for i := 0; i < 40; i++ {
+		want, wantOk := st.Get(i)
+		got, ok := parsed.Get(i)
+		if ok != wantOk || got != want {
+			t.Errorf("Key %d: expected (%v, %v), got (%v, %v)", i, want, wantOk, got, ok)
+		}
+	}
+
+	if _, err := ParseVisualize("not a dump"); err == nil {
+		t.Error("Expected error for input without an Entries section")
+	}
+}
+
+func TestVisualizeN(t *testing.T) {
+	st := New()
+	for i := 0; i < 1000; i++ {
+		st.Put(i, i)
+	}
+
+	const budget = 10
+	out := st.VisualizeN(budget)
+	groupRows := strings.Count(out, "Group ")
+	entries := strings.SplitN(out, "Entries:\n", 2)[1]
+	entryRows := strings.Count(entries, "\n") - 3 // column header, separator and note
+	if groupRows > budget || entryRows > budget {
+		t.Errorf("Expected at most %d rows per section, got %d groups and %d entries", budget, groupRows, entryRows)
+	}
+	if want := fmt.Sprintf("(%d more entries omitted)", st.Size()-budget); !strings.Contains(out, want) {
+		t.Errorf("Expected omission note %q in output:\n%s", want, out)
+	}
+	if want := fmt.Sprintf("(%d more groups omitted)", st.groupCount-budget); !strings.Contains(out, want) {
+		t.Errorf("Expected omission note %q in output:\n%s", want, out)
+	}
+	if !strings.Contains(out, "Size: 1000") {
+		t.Error("Expected the summary to report the full size")
+	}
+
+	parsed, err := ParseVisualize(out)
+	if err != nil {
+		t.Fatalf("ParseVisualize of truncated output failed: %v", err)
+	}
+	if parsed.Size() != budget {
+		t.Errorf("Expected %d parsed entries, got %d", budget, parsed.Size())
+	}
+
+	if small := New(); strings.Contains(small.VisualizeN(budget), "omitted") {
+		t.Error("Expected no omission note when everything fits")
+	}
+}
+
+func TestDetectStringificationCollisions(t *testing.T) {
+	st := New()
+	st.Put(1, "int")
+	st.Put("1", "string")
+	st.Put(2, "alone")
+
+	collisions := st.DetectStringificationCollisions()
+	if len(collisions) != 1 {
+		t.Fatalf("Expected 1 colliding group, got %d: %v", len(collisions), collisions)
+	}
+	group := collisions[0]
+	if len(group) != 2 {
+		t.Fatalf("Expected 2 keys in group, got %v", group)
+	}
+	seen := map[any]bool{group[0]: true, group[1]: true}
+	if !seen[1] || !seen["1"] {
+		t.Errorf("Expected group {1, \"1\"}, got %v", group)
+	}
+}
+
+// clusteredKeys returns n integer keys whose H1 is a multiple of 64 under
+// st's seed, so they share a home group for every table up to 64 groups
+func clusteredKeys(st *SwissTable, n int) []int {
+	keys := make([]int, 0, n)
+	for k := 0; len(keys) < n; k++ {
+		if h1, _ := st.hashKey(k); h1%64 == 0 {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestDeleteKeepsProbeChain(t *testing.T) {
+	st := New()
+	st.resizeTo(4 * groupSize)
+
+	// Every key starts probing at the same group, so the chain spills into
+	// the next groups
+	keys := clusteredKeys(st, 2*groupSize+4)
+	for _, k := range keys {
+		st.Put(k, k)
+	}
+	first, _ := st.SlotOf(keys[0])
+	last, _ := st.SlotOf(keys[len(keys)-1])
+	if first/groupSize == last/groupSize {
+		t.Fatal("Expected the chain to span several groups")
+	}
+
+	// Deleting in the middle of the chain must not hide keys placed after it
+	st.Delete(keys[3])
+	for _, k := range keys[4:] {
+		if v, ok := st.Get(k); !ok || v != k {
+			t.Errorf("Key %d: expected (%d, true) after deleting %d, got (%v, %v)", k, k, keys[3], v, ok)
+		}
+	}
+
+	// The tombstone is reused, and the reinserted key stays reachable
+	st.Put(keys[3], -1)
+	if idx, _ := st.SlotOf(keys[3]); idx/groupSize != first/groupSize {
+		t.Errorf("Expected the deleted slot in group %d to be reused, key landed in group %d", first/groupSize, idx/groupSize)
+	}
+	checkInvariants(t, st)
+}
+
+func TestChurnKeepsEmptySlots(t *testing.T) {
+	st := New()
+	for i := 0; i < 1000; i++ {
+		st.Put(i, i)
+	}
+	// Replacing the oldest key on every step keeps 1000 live keys while
+	// deletes eventually touch every slot
+	for i := 1000; i < 200000; i++ {
+		st.Put(i, i)
+		st.Delete(i - 1000)
+	}
+
+	// Tombstones count toward the load factor, so at least a quarter of the
+	// slots stays empty and the table rehashes in place rather than growing
+	empty := 0
+	for i := range st.metadata {
+		empty += bits.OnesCount16(matchEmpty(&st.metadata[i]))
+	}
+	if empty < len(st.entries)/4 {
+		t.Errorf("Expected at least %d empty slots after churn, got %d", len(st.entries)/4, empty)
+	}
+	if len(st.entries) > 4096 {
+		t.Errorf("Expected churn not to keep growing the table, got %d slots", len(st.entries))
+	}
+
+	st.EnableInstrumentation()
+	for k := -1; k >= -1000; k-- {
+		if _, ok := st.Get(k); ok {
+			t.Fatalf("Key %d: expected a miss", k)
+		}
+	}
+	if st.maxProbed > 8 {
+		t.Errorf("Expected misses to probe at most 8 of %d groups, got %d", st.groupCount, st.maxProbed)
+	}
+	checkInvariants(t, st)
+}
+
+// collidingKey formats identically for every value, so the default hasher,
+// which hashes such keys by their %v form, gives all of them the same hash
+// under any seed
+type collidingKey int
+
+func (collidingKey) String() string { return "collide" }
+
+func TestSetMaxComparisons(t *testing.T) {
+	st := New()
+	for i := 0; i < 10; i++ {
+		st.Put(collidingKey(i), i)
+	}
+	st.SetMaxComparisons(3)
+
+	// The third compare is the match, so the bound is not exceeded
+	if v, ok := st.Get(collidingKey(2)); !ok || v != 2 || st.Reseeds() != 0 {
+		t.Errorf("Expected (2, true) without a reseed, got (%v, %v) after %d reseeds", v, ok, st.Reseeds())
+	}
+	if v, ok := st.Get(collidingKey(9)); !ok || v != 9 {
+		t.Errorf("Expected (9, true) after the reseed, got (%v, %v)", v, ok)
+	}
+	if st.Reseeds() != 1 {
+		t.Errorf("Expected 1 reseed after exceeding 3 compares, got %d", st.Reseeds())
+	}
+
+	// The keys collide under every seed, so the reseed did not help and the
+	// bound is gone: later lookups no longer rehash
+	if st.maxComparisons != 0 {
+		t.Errorf("Expected the bound to be removed after a futile reseed, got %d", st.maxComparisons)
+	}
+	st.Get(collidingKey(8))
+	if !st.Delete(collidingKey(5)) || st.Reseeds() != 1 {
+		t.Errorf("Expected Delete to succeed without further reseeds, got %d reseeds", st.Reseeds())
+	}
+	checkInvariants(t, st)
+
+	// Distinct hashes never reach a full compare that fails
+	plain := New()
+	plain.SetMaxComparisons(1)
+	for i := 0; i < 1000; i++ {
+		plain.Put(i, i)
+	}
+	for i := 0; i < 2000; i++ {
+		plain.Get(i)
+	}
+	if plain.Reseeds() != 0 {
+		t.Errorf("Expected no reseeds for well-distributed keys, got %d", plain.Reseeds())
+	}
+
+	hashed, _ := NewBuilder().WithHasher(func(key any) uint64 { return 42 }).Build()
+	hashed.SetMaxComparisons(1)
+	for i := 0; i < 5; i++ {
+		hashed.Put(i, i)
+	}
+	if v, ok := hashed.Get(4); !ok || v != 4 || hashed.Reseeds() != 0 || hashed.maxComparisons != 0 {
+		t.Errorf("Expected a custom hasher to finish the lookup without reseeding and drop the bound, got (%v, %v)", v, ok)
+	}
+}
+
+func TestSetMaxLookupProbes(t *testing.T) {
+	st := New()
+	st.resizeTo(8 * groupSize)
+	st.EnableInstrumentation()
+	keys := clusteredKeys(st, 5*groupSize)
+	for _, k := range keys {
+		st.Put(k, k)
+	}
+
+	for _, k := range keys {
+		st.Get(k)
+	}
+	if st.maxProbed < 5 {
+		t.Fatalf("Expected clustered keys to need at least 5 probes, got %d", st.maxProbed)
+	}
+
+	st.SetMaxLookupProbes(2)
+	st.maxProbed = 0
+	found := 0
+	for _, k := range keys {
+		if _, ok := st.Get(k); ok {
+			found++
+		}
+		st.Get(-k - 1)
+	}
+	if st.maxProbed > 2 {
+		t.Errorf("Expected at most 2 probes per lookup, got %d", st.maxProbed)
+	}
+	if found != 2*groupSize {
+		t.Errorf("Expected only the %d keys in the first two groups to be found, got %d", 2*groupSize, found)
+	}
+
+	// Inserts still see every key, so the bound never duplicates one
+	for _, k := range keys {
+		st.Put(k, -k)
+	}
+	if st.Size() != len(keys) {
+		t.Errorf("Expected size %d after updates, got %d", len(keys), st.Size())
+	}
+
+	st.SetMaxLookupProbes(0)
+	checkInvariants(t, st)
+}
+
+func TestAdaptiveLoadFactor(t *testing.T) {
+	static := New()
+	adaptive := New()
+	adaptive.hashSeed = static.hashSeed
+	adaptive.EnableAdaptiveLoadFactor()
+
+	keys := clusteredKeys(static, 90)
+	for _, k := range keys {
+		static.Put(k, k)
+		adaptive.Put(k, k)
+	}
+
+	if adaptive.maxLoad >= loadFactor {
+		t.Errorf("Expected adaptive load factor below %v, got %v", loadFactor, adaptive.maxLoad)
+	}
+	if len(adaptive.entries) <= len(static.entries) {
+		t.Errorf("Expected adaptive table to have grown past %d slots, got %d",
+			len(static.entries), len(adaptive.entries))
+	}
+	for _, k := range keys {
+		if v, ok := adaptive.Get(k); !ok || v != k {
+			t.Errorf("Expected (%d, true), got (%v, %v)", k, v, ok)
+		}
+	}
+	if static.maxLoad != loadFactor {
+		t.Errorf("Expected static load factor to stay %v, got %v", loadFactor, static.maxLoad)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i)
+	}
+	st.Reserve(5000)
+	capacity := len(st.entries)
+	st.Reserve(4000)
+	st.Reserve(-10)
+	if len(st.entries) != capacity {
+		t.Errorf("Expected Reserve to be a no-op with room to spare, capacity went %d -> %d", capacity, len(st.entries))
+	}
+	for i := 0; i < 100; i++ {
+		if v, ok := st.Get(i); !ok || v != i {
+			t.Errorf("Key %d: expected (%d, true) after Reserve, got (%v, %v)", i, i, v, ok)
+		}
+	}
+
+	for i := 100; i < 5100; i++ {
+		st.Put(i, i)
+		if len(st.entries) != capacity {
+			t.Fatalf("Insert %d resized the table from %d to %d slots", i-100, capacity, len(st.entries))
+		}
+	}
+
+	checkInvariants(t, st)
+}
+
+func TestTrimToFit(t *testing.T) {
+	st := New()
+	for i := 0; i < 10000; i++ {
+		st.Put(i, i)
+	}
+	full := len(st.entries)
+	for i := 0; i < 10000; i++ {
+		if i%10 != 0 {
+			st.Delete(i)
+		}
+	}
+
+	st.TrimToFit()
+	if got, want := len(st.entries), st.capacityFor(1000); got != want || got >= full {
+		t.Errorf("Expected %d slots after trimming from %d, got %d", want, full, got)
+	}
+	for i := 0; i < 10000; i++ {
+		v, ok := st.Get(i)
+		if want := i%10 == 0; ok != want || (ok && v != i) {
+			t.Errorf("Key %d: expected present=%v, got (%v, %v)", i, want, v, ok)
+		}
+	}
+	checkInvariants(t, st)
+
+	trimmed := len(st.entries)
+	st.TrimToFit()
+	if len(st.entries) != trimmed {
+		t.Errorf("Expected a second trim to be a no-op, capacity went %d -> %d", trimmed, len(st.entries))
+	}
+
+	st.Clear()
+	st.TrimToFit()
+	if len(st.entries) != initialSize {
+		t.Errorf("Expected an empty table to trim to %d slots, got %d", initialSize, len(st.entries))
+	}
+}
+
+func TestReserveFromHistogram(t *testing.T) {
+	// 99 runs of up to 500 keys and a single huge outlier
+	sizes := make([]int, 100)
+	for i := range sizes {
+		sizes[i] = 500 - i
+	}
+	sizes[99] = 100000
+
+	st := New()
+	st.Put("kept", 1)
+	st.ReserveFromHistogram(sizes)
+
+	capacity := len(st.entries)
+	if float64(500)/float64(capacity) > loadFactor {
+		t.Errorf("Capacity %d cannot hold the 99th percentile size 500", capacity)
+	}
+	if capacity >= 100000 {
+		t.Errorf("Capacity %d was sized for the outlier", capacity)
+	}
+	if capacity%groupSize != 0 || st.groupCount != capacity/groupSize {
+		t.Errorf("Inconsistent layout: %d slots, %d groups", capacity, st.groupCount)
+	}
+	if v, ok := st.Get("kept"); !ok || v != 1 {
+		t.Errorf("Expected (1, true), got (%v, %v)", v, ok)
+	}
+
+	for i := 1; i < 500; i++ {
+		st.Put(i, i)
+	}
+	if len(st.entries) != capacity {
+		t.Errorf("Expected no resize up to 500 entries, capacity went %d -> %d", capacity, len(st.entries))
+	}
+}
+
+func TestNewPreDistributed(t *testing.T) {
+	st := NewPreDistributed()
+	st.EnableInstrumentation()
+	rng := rand.New(rand.NewSource(1))
+	keys := make([]uint64, 500)
+	for i := range keys {
+		keys[i] = rng.Uint64()
+		st.Put(keys[i], i)
+	}
+
+	for i, k := range keys {
+		want, _ := preDistributedHash(k)
+		if h1, h2 := st.hashKey(k); h1 != want>>h2Bits || h2 != uint8(want&h2Mask) {
+			t.Errorf("Key %d: expected hashes (%d, %d), got (%d, %d)", k, want>>h2Bits, want&h2Mask, h1, h2)
+		}
+		if v, ok := st.Get(k); !ok || v != i {
+			t.Errorf("Key %d: expected (%d, true), got (%v, %v)", k, i, v, ok)
+		}
+	}
+	if got := st.BytesHashed(); got != 0 {
+		t.Errorf("Expected integer keys to skip maphash, hashed %d bytes", got)
+	}
+	checkInvariants(t, st)
+
+	// Other key types still go through maphash
+	st.Put("text", 1)
+	if v, ok := st.Get("text"); !ok || v != 1 {
+		t.Errorf("Expected (1, true) for a string key, got (%v, %v)", v, ok)
+	}
+	if got := st.BytesHashed(); got == 0 {
+		t.Error("Expected a string key to be hashed with maphash")
+	}
+}
+
+func TestRingPosition(t *testing.T) {
+	seed := maphash.MakeSeed()
+	if RingPosition("node-key", seed) != RingPosition("node-key", seed) {
+		t.Error("Expected the same position for the same key and seed")
+	}
+
+	// The table places keys with the same hash
+	st := New()
+	st.hashSeed = seed
+	if h1, h2 := st.hashKey(42); h1<<h2Bits|uint64(h2) != RingPosition(42, seed) {
+		t.Error("Expected RingPosition to match the table's hash")
+	}
+
+	// Spread over the ring: count keys per sixteenth of the range
+	const keys = 16000
+	var arcs [16]int
+	for i := 0; i < keys; i++ {
+		arcs[RingPosition(i, seed)>>60]++
+	}
+	for i, n := range arcs {
+		if n < keys/16*3/4 || n > keys/16*5/4 {
+			t.Errorf("Arc %d: expected about %d keys, got %d", i, keys/16, n)
+		}
+	}
+}
+
+func TestHashKeyTypes(t *testing.T) {
+	st := New()
+	st.Put(1, "int")
+	st.Put("1", "string")
+	st.Put(int64(1), "int64")
+	st.Put(uint64(1), "uint64")
+	st.Put(int32(1), "int32")
+	st.Put(float64(1), "float64")
+
+	if st.Size() != 6 {
+		t.Errorf("Expected 6 distinct keys, got %d", st.Size())
+	}
+	for key, want := range map[any]string{1: "int", "1": "string", int64(1): "int64", uint64(1): "uint64", int32(1): "int32", float64(1): "float64"} {
+		if v, ok := st.Get(key); !ok || v != want {
+			t.Errorf("Key %T(%v): expected (%s, true), got (%v, %v)", key, key, want, v, ok)
+		}
+	}
+
+	// 1 and "1" no longer share a hash stream
+	h1, _ := st.hashKey(1)
+	h1s, _ := st.hashKey("1")
+	if h1 == h1s {
+		t.Error("Expected 1 and \"1\" to hash differently")
+	}
+
+	var intKey, strKey any = 123456, "a string key"
+	if allocs := testing.AllocsPerRun(100, func() { st.hashKey(intKey); st.hashKey(strKey) }); allocs != 0 {
+		t.Errorf("Expected hashing common key types not to allocate, got %v allocs", allocs)
+	}
+}
+
 func TestSwissTableBasic(t *testing.T) {
 	st := New()
 
-	// Test simple put and get
-	st.Put(1, "one")
-	if val, ok := st.Get(1); !ok || val != "one" {
-		t.Errorf("Expected (one, true), got (%v, %v)", val, ok)
+	// Test simple put and get
+	st.Put(1, "one")
+	if val, ok := st.Get(1); !ok || val != "one" {
+		t.Errorf("Expected (one, true), got (%v, %v)", val, ok)
+	}
+
+	// Test overwrite
+	st.Put(1, "new-one")
+	if val, ok := st.Get(1); !ok || val != "new-one" {
+		t.Errorf("Expected (new-one, true), got (%v, %v)", val, ok)
+	}
+
+	// Test delete
+	if !st.Delete(1) {
+		t.Error("Delete should return true for existing key")
+	}
+	if val, ok := st.Get(1); ok {
+		t.Errorf("Expected not found after delete, got (%v, %v)", val, ok)
+	}
+}
+
+func TestCapAndLoadFactor(t *testing.T) {
+	st := New()
+	if st.Cap() != initialSize || st.LoadFactor() != 0 {
+		t.Errorf("Expected an empty table to report (%d, 0), got (%d, %v)", initialSize, st.Cap(), st.LoadFactor())
+	}
+
+	st.Put(0, 0)
+	prev := st.LoadFactor()
+	for i := 1; i < 1000; i++ {
+		capacity := st.Cap()
+		st.Put(i, i)
+		lf := st.LoadFactor()
+		if lf > loadFactor {
+			t.Fatalf("Insert %d: load factor %v exceeds the %v threshold", i, lf, loadFactor)
+		}
+		if st.Cap() == capacity && lf <= prev {
+			t.Fatalf("Insert %d: expected the load factor to rise from %v, got %v", i, prev, lf)
+		}
+		prev = lf
+	}
+}
+
+func TestNewWithCapacity(t *testing.T) {
+	const n = 1000
+	st := NewWithCapacity(n)
+	slots := len(st.entries)
+	if want := st.capacityFor(n); slots != want || st.groupCount*groupSize != slots || len(st.occupancy) != st.groupCount {
+		t.Fatalf("Expected %d slots in %d groups, got %d slots in %d groups", want, want/groupSize, slots, st.groupCount)
+	}
+	for i := 0; i < n; i++ {
+		st.Put(i, i)
+	}
+	if len(st.entries) != slots {
+		t.Errorf("Expected no resize while inserting %d keys, capacity grew from %d to %d", n, slots, len(st.entries))
+	}
+	checkInvariants(t, st)
+
+	if got := len(NewWithCapacity(0).entries); got != initialSize {
+		t.Errorf("Expected NewWithCapacity(0) to match New with %d slots, got %d", initialSize, got)
+	}
+}
+
+func TestControlByteEncoding(t *testing.T) {
+	var group metadata
+	for i := range group.bytes {
+		group.bytes[i] = ctrlEmpty
+	}
+	group.bytes[1] = 0 // H2 of zero is a valid full slot
+	group.bytes[2] = 0x7F
+	group.bytes[3] = ctrlDeleted
+	group.bytes[9] = 0x7F
+	group.bytes[15] = ctrlDeleted
+
+	full := uint16(1<<1 | 1<<2 | 1<<9)
+	deleted := uint16(1<<3 | 1<<15)
+	if got := matchFull(&group); got != full {
+		t.Errorf("Expected full mask %016b, got %016b", full, got)
+	}
+	if got := matchEmptyOrDeleted(&group); got != ^full {
+		t.Errorf("Expected empty-or-deleted mask %016b, got %016b", ^full, got)
+	}
+	if got := matchEmpty(&group); got != ^full&^deleted {
+		t.Errorf("Expected empty mask %016b, got %016b", ^full&^deleted, got)
+	}
+	if got := matchGroup(&group, 0x7F); got != 1<<2|1<<9 {
+		t.Errorf("Expected H2 mask %016b, got %016b", uint16(1<<2|1<<9), got)
+	}
+	if got := matchGroup(&group, 0); got != 1<<1 {
+		t.Errorf("Expected H2 0 to match only slot 1, got %016b", got)
+	}
+
+	// No probe sequence continues past a group with an empty slot, so a
+	// delete there empties the slot again
+	st := New()
+	for i := 0; i < 10; i++ {
+		st.Put(i, i)
+	}
+	idx, _ := st.SlotOf(3)
+	st.Delete(3)
+	if ctrl := st.metadata[idx/groupSize].bytes[idx%groupSize]; ctrl != ctrlEmpty {
+		t.Errorf("Expected empty %#x after delete from a sparse group, got %#x", ctrlEmpty, ctrl)
+	}
+	for i := 0; i < 10; i++ {
+		if _, ok := st.Get(i); ok != (i != 3) {
+			t.Errorf("Key %d: expected found=%v after delete", i, i != 3)
+		}
+	}
+	checkInvariants(t, st)
+
+	// A delete from a full group leaves a tombstone, which keeps the keys
+	// that spilled past it reachable
+	st = New()
+	st.resizeTo(4 * groupSize)
+	keys := clusteredKeys(st, groupSize+4)
+	for _, k := range keys {
+		st.Put(k, k)
+	}
+	idx, _ = st.SlotOf(keys[0])
+	st.Delete(keys[0])
+	if ctrl := st.metadata[idx/groupSize].bytes[idx%groupSize]; ctrl != ctrlDeleted {
+		t.Errorf("Expected tombstone %#x after delete from a full group, got %#x", ctrlDeleted, ctrl)
+	}
+	for _, k := range keys[1:] {
+		if _, ok := st.Get(k); !ok {
+			t.Errorf("Key %d: expected to survive the delete", k)
+		}
+	}
+	checkInvariants(t, st)
+}
+
+func TestContains(t *testing.T) {
+	st := New()
+	if st.Contains(1) {
+		t.Error("Expected an empty table to contain nothing")
+	}
+	st.Put(1, nil)
+	st.Put("two", 2)
+	if !st.Contains(1) || !st.Contains("two") {
+		t.Error("Expected present keys to be contained, even with a nil value")
+	}
+	if st.Contains(3) {
+		t.Error("Expected a missing key not to be contained")
+	}
+	st.Delete(1)
+	if st.Contains(1) {
+		t.Error("Expected a deleted key not to be contained")
+	}
+}
+
+func TestSlotOf(t *testing.T) {
+	st := New()
+	for i := 0; i < 10; i++ {
+		st.Put(i, i*i)
+	}
+
+	for i := 0; i < 10; i++ {
+		slot, ok := st.SlotOf(i)
+		if !ok {
+			t.Fatalf("Expected key %d to have a slot", i)
+		}
+		if st.entries[slot].key != i {
+			t.Errorf("Slot %d holds key %v, expected %d", slot, st.entries[slot].key, i)
+		}
+	}
+
+	if slot, ok := st.SlotOf(42); ok {
+		t.Errorf("Expected no slot for missing key, got %d", slot)
+	}
+}
+
+func TestControlByteOf(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i)
+	}
+
+	for i := 0; i < 100; i++ {
+		ctrl, ok := st.ControlByteOf(i)
+		if _, h2 := st.hashKey(i); !ok || ctrl != h2 {
+			t.Errorf("Key %d: expected control byte (%d, true), got (%d, %v)", i, h2, ctrl, ok)
+		}
+	}
+	if ctrl, ok := st.ControlByteOf(-1); ok {
+		t.Errorf("Expected no control byte for a missing key, got %d", ctrl)
+	}
+}
+
+func TestPutColumns(t *testing.T) {
+	st := New()
+	if err := st.PutColumns([]any{1, 2}, []any{"one"}); err == nil {
+		t.Error("Expected an error for mismatched lengths")
+	}
+	if st.Size() != 0 {
+		t.Errorf("Expected nothing inserted on a length mismatch, got size %d", st.Size())
+	}
+
+	keys := make([]any, 500)
+	values := make([]any, 500)
+	for i := range keys {
+		keys[i] = i
+		values[i] = i * 10
+	}
+	if err := st.PutColumns(keys, values); err != nil {
+		t.Fatalf("PutColumns failed: %v", err)
+	}
+	if st.Size() != 500 {
+		t.Errorf("Expected size 500, got %d", st.Size())
+	}
+	if want := st.capacityFor(500) / groupSize; st.groupCount != want {
+		t.Errorf("Expected the table sized once to %d groups, got %d", want, st.groupCount)
+	}
+	for i := 0; i < 500; i++ {
+		if v, ok := st.Get(i); !ok || v != i*10 {
+			t.Errorf("Key %d: expected (%d, true), got (%v, %v)", i, i*10, v, ok)
+		}
+	}
+
+	st.SetKeyValidator(func(key any) error {
+		if key == "bad" {
+			return errors.New("rejected")
+		}
+		return nil
+	})
+	if err := st.PutColumns([]any{"ok", "bad"}, []any{1, 2}); err == nil {
+		t.Error("Expected the validator error to be returned")
+	}
+}
+
+func TestPutMany(t *testing.T) {
+	m := make(map[any]any, 10000)
+	for i := 0; i < 10000; i++ {
+		m[i] = fmt.Sprint(i)
+	}
+	st := New()
+	st.Put("existing", 1)
+
+	resizes := 0
+	resizeHook = func(newSize int) int {
+		resizes++
+		return newSize
+	}
+	defer func() { resizeHook = nil }()
+	st.PutMany(m)
+	if resizes != 1 {
+		t.Errorf("Expected exactly one resize, got %d", resizes)
+	}
+
+	if st.Size() != len(m)+1 {
+		t.Errorf("Expected size %d, got %d", len(m)+1, st.Size())
+	}
+	for k, want := range m {
+		if v, ok := st.Get(k); !ok || v != want {
+			t.Errorf("Key %v: expected (%v, true), got (%v, %v)", k, want, v, ok)
+		}
+	}
+	checkInvariants(t, st)
+}
+
+func TestMapRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 20; round++ {
+		m := make(map[any]any)
+		for i := rng.Intn(500); i > 0; i-- {
+			var k any
+			switch rng.Intn(4) {
+			case 0:
+				k = rng.Intn(1000)
+			case 1:
+				k = strconv.Itoa(rng.Intn(1000))
+			case 2:
+				k = rng.Float64()
+			case 3:
+				k = [2]int{rng.Intn(10), rng.Intn(10)}
+			}
+			m[k] = rng.Int63()
+		}
+
+		st := FromMap(m)
+		if st.Cap() != st.capacityFor(len(m)) {
+			t.Errorf("Round %d: expected %d slots for %d pairs, got %d", round, st.capacityFor(len(m)), len(m), st.Cap())
+		}
+		if got := st.ToMap(); !maps.Equal(got, m) {
+			t.Errorf("Round %d: round trip of %d pairs returned %d different pairs", round, len(m), len(got))
+		}
+	}
+
+	if m := New().ToMap(); m == nil || len(m) != 0 {
+		t.Errorf("Expected a non-nil empty map, got %#v", m)
+	}
+	if st := FromMap(nil); st.Size() != 0 {
+		t.Errorf("Expected an empty table from a nil map, got size %d", st.Size())
+	}
+}
+
+func BenchmarkPutMany(b *testing.B) {
+	m := make(map[any]any, 10000)
+	for i := 0; i < 10000; i++ {
+		m[i] = i
+	}
+
+	b.Run("Put", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			st := New()
+			for k, v := range m {
+				st.Put(k, v)
+			}
+		}
+	})
+	b.Run("PutMany", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			New().PutMany(m)
+		}
+	})
+}
+
+func TestBuildParallel(t *testing.T) {
+	pairs := make([][2]any, 2000)
+	for i := range pairs {
+		// Repeat every key once so later pairs must win
+		pairs[i] = [2]any{i % 1000, i}
+	}
+
+	serial := New()
+	for _, p := range pairs {
+		serial.Put(p[0], p[1])
+	}
+
+	for _, workers := range []int{1, 3, 8} {
+		st := BuildParallel(pairs, workers)
+		if st.Size() != serial.Size() {
+			t.Errorf("workers=%d: expected size %d, got %d", workers, serial.Size(), st.Size())
+		}
+		for k := 0; k < 1000; k++ {
+			want, _ := serial.Get(k)
+			if got, ok := st.Get(k); !ok || got != want {
+				t.Errorf("workers=%d: key %d expected (%v, true), got (%v, %v)", workers, k, want, got, ok)
+			}
+		}
+	}
+}
+
+func BenchmarkBuildParallel(b *testing.B) {
+	pairs := make([][2]any, 4096)
+	for i := range pairs {
+		pairs[i] = [2]any{fmt.Sprintf("key-%d", i), i}
+	}
+
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				BuildParallel(pairs, workers)
+			}
+		})
+	}
+}
+
+func TestApproxDistinctKeys(t *testing.T) {
+	st := New()
+	if got := st.ApproxDistinctKeys(); got != 0 {
+		t.Errorf("Expected 0 with estimate disabled, got %d", got)
+	}
+	st.EnableDistinctEstimate()
+
+	const distinct = 20000
+	for i := 0; i < distinct; i++ {
+		st.Put(i, i)
+		// Overwrites and deletes must not change the distinct count
+		st.Put(i, -i)
+		if i%3 == 0 {
+			st.Delete(i)
+		}
+	}
+
+	got := st.ApproxDistinctKeys()
+	if diff := math.Abs(float64(got)-distinct) / distinct; diff > 0.03 {
+		t.Errorf("Expected estimate within 3%% of %d, got %d (%.2f%% off)", distinct, got, diff*100)
+	}
+}
+
+func TestUpdateAll(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i)
+	}
+	st.Delete(50)
+	slots := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		if idx, ok := st.SlotOf(i); ok {
+			slots[i] = idx
+		}
+	}
+	gen := st.Generation()
+
+	st.UpdateAll(func(key, value any) any { return value.(int) + 1 })
+
+	for i, want := range slots {
+		if idx, ok := st.SlotOf(i); !ok || idx != want {
+			t.Errorf("Key %d: expected slot %d, got (%d, %v)", i, want, idx, ok)
+		}
+		if v, _ := st.Get(i); v != i+1 {
+			t.Errorf("Key %d: expected %d after update, got %v", i, i+1, v)
+		}
+	}
+	updated := 0
+	st.RangeSince(gen, func(key, value any) bool { updated++; return true })
+	if updated != 99 {
+		t.Errorf("Expected 99 entries updated since generation %d, got %d", gen, updated)
+	}
+	checkInvariants(t, st)
+}
+
+func TestMapValues(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i)
+	}
+	keys, slots := st.Keys(), st.Cap()
+
+	st.MapValues(func(key, value any) any { return value.(int) * 2 })
+	if !slices.Equal(st.Keys(), keys) || st.Cap() != slots || st.Size() != 100 {
+		t.Error("Expected keys, their order and the capacity to be unchanged")
+	}
+	for i := 0; i < 100; i++ {
+		if v, ok := st.Get(i); !ok || v != i*2 {
+			t.Errorf("Key %d: expected (%d, true), got (%v, %v)", i, i*2, v, ok)
+		}
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	st := New()
+	for i := 0; i < 20; i++ {
+		st.Put(i, i*10)
+	}
+
+	if !st.SoftDelete(3) || !st.SoftDelete(4) || !st.SoftDelete(5) {
+		t.Fatal("Expected SoftDelete to succeed for live keys")
+	}
+	if st.SoftDelete(100) {
+		t.Error("Expected SoftDelete to fail for an absent key")
+	}
+	if st.Size() != 17 {
+		t.Errorf("Expected size 17 after soft deletes, got %d", st.Size())
+	}
+	if _, ok := st.Get(3); ok {
+		t.Error("Expected Get to miss a soft-deleted key")
+	}
+	if v, deleted, ok := st.GetDeleted(3); !ok || !deleted || v != 30 {
+		t.Errorf("Expected (30, true, true) for a soft-deleted key, got (%v, %v, %v)", v, deleted, ok)
+	}
+	if v, deleted, ok := st.GetDeleted(7); !ok || deleted || v != 70 {
+		t.Errorf("Expected (70, false, true) for a live key, got (%v, %v, %v)", v, deleted, ok)
+	}
+
+	// Putting the key again supersedes the soft-deleted value
+	st.Put(3, -1)
+	if v, deleted, ok := st.GetDeleted(3); !ok || deleted || v != -1 {
+		t.Errorf("Expected (-1, false, true) after Put, got (%v, %v, %v)", v, deleted, ok)
+	}
+
+	if !st.HardDelete(4) {
+		t.Error("Expected HardDelete to remove a soft-deleted key")
+	}
+	if _, _, ok := st.GetDeleted(4); ok {
+		t.Error("Expected a hard-deleted key to be gone")
+	}
+
+	if n := st.PurgeSoftDeleted(); n != 1 {
+		t.Errorf("Expected 1 purged entry, got %d", n)
+	}
+	if _, _, ok := st.GetDeleted(5); ok {
+		t.Error("Expected a purged key to be gone")
+	}
+	checkInvariants(t, st)
+}
+
+func TestClear(t *testing.T) {
+	st := New()
+	for i := 0; i < 200; i++ {
+		st.Put(i, i)
+	}
+	st.Delete(7)
+	st.SoftDelete(8)
+	groups, entries := st.groupCount, &st.entries[0]
+
+	st.Clear()
+	if st.Size() != 0 {
+		t.Errorf("Expected size 0 after Clear, got %d", st.Size())
+	}
+	if st.groupCount != groups || &st.entries[0] != entries {
+		t.Error("Expected Clear to keep the capacity and backing arrays")
+	}
+	for i := 0; i < 200; i++ {
+		if _, ok := st.Get(i); ok {
+			t.Errorf("Key %d: expected miss after Clear", i)
+		}
+	}
+	if _, _, ok := st.GetDeleted(8); ok {
+		t.Error("Expected Clear to drop soft-deleted entries")
+	}
+	for idx, e := range st.entries {
+		if e != (entry{}) {
+			t.Errorf("Slot %d: expected a zeroed entry, got %+v", idx, e)
+			break
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		st.Put(i, -i)
+	}
+	if st.Size() != 100 || st.groupCount != groups {
+		t.Errorf("Expected 100 entries in %d groups, got %d in %d", groups, st.Size(), st.groupCount)
+	}
+	checkInvariants(t, st)
+}
+
+func TestGetAndDelete(t *testing.T) {
+	st := New()
+	st.Put("job", 42)
+	st.Put("other", 1)
+
+	if v, loaded := st.GetAndDelete("job"); !loaded || v != 42 {
+		t.Errorf("Expected (42, true), got (%v, %v)", v, loaded)
+	}
+	if st.Contains("job") || st.Size() != 1 {
+		t.Errorf("Expected the key to be gone and size 1, got size %d", st.Size())
+	}
+	if v, loaded := st.GetAndDelete("job"); loaded || v != nil {
+		t.Errorf("Expected (nil, false) for an absent key, got (%v, %v)", v, loaded)
+	}
+	checkInvariants(t, st)
+}
+
+func TestDrainIf(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i*10)
+	}
+
+	drained := st.DrainIf(func(key, value any) bool { return key.(int)%3 == 0 })
+	if len(drained) != 34 {
+		t.Errorf("Expected 34 drained entries, got %d", len(drained))
+	}
+	for _, kv := range drained {
+		if kv[0].(int)%3 != 0 || kv[1] != kv[0].(int)*10 {
+			t.Errorf("Unexpected drained entry %v", kv)
+		}
+	}
+
+	if st.Size() != 66 {
+		t.Errorf("Expected size 66 after drain, got %d", st.Size())
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := st.Get(i); ok != (i%3 != 0) {
+			t.Errorf("Key %d: expected found=%v after drain", i, i%3 != 0)
+		}
+	}
+	checkInvariants(t, st)
+
+	if again := st.DrainIf(func(key, value any) bool { return key.(int)%3 == 0 }); len(again) != 0 {
+		t.Errorf("Expected nothing left to drain, got %v", again)
+	}
+}
+
+func TestDeleteFunc(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i)
+	}
+
+	if n := st.DeleteFunc(func(key, value any) bool { return key.(int)%2 == 0 }); n != 50 {
+		t.Errorf("Expected 50 removed, got %d", n)
+	}
+	if st.Size() != 50 {
+		t.Errorf("Expected size 50, got %d", st.Size())
+	}
+	st.Range(func(key, value any) bool {
+		if key.(int)%2 == 0 {
+			t.Errorf("Even key %v survived", key)
+		}
+		return true
+	})
+	for i := 1; i < 100; i += 2 {
+		if v, ok := st.Get(i); !ok || v != i {
+			t.Errorf("Key %d: expected (%d, true), got (%v, %v)", i, i, v, ok)
+		}
+	}
+	if tombstones := st.Stats().Tombstones; tombstones != st.tombstones {
+		t.Errorf("Expected %d tombstones, got %d", st.tombstones, tombstones)
+	}
+	checkInvariants(t, st)
+}
+
+func TestClone(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.PutMeta(i, i*10, fmt.Sprint("meta", i))
+	}
+	st.SoftDelete(99)
+
+	c := st.Clone()
+	if c.hashSeed != st.hashSeed || c.Visualize() != st.Visualize() {
+		t.Error("Expected the clone to share the seed and slot layout")
+	}
+
+	st.Delete(5)
+	st.Put(200, 2000)
+	st.PurgeSoftDeleted()
+	if v, meta, ok := c.GetMeta(5); !ok || v != 50 || meta != "meta5" {
+		t.Errorf("Expected the clone to keep (50, meta5, true), got (%v, %v, %v)", v, meta, ok)
+	}
+	if c.Contains(200) || c.Size() != 99 {
+		t.Errorf("Expected the clone to be unaffected by the original's puts, got size %d", c.Size())
+	}
+	if _, deleted, ok := c.GetDeleted(99); !ok || !deleted {
+		t.Error("Expected the clone to keep its soft-deleted entries")
+	}
+
+	c.Put(5, -5)
+	if st.Contains(5) {
+		t.Error("Expected a put on the clone not to affect the original")
+	}
+	checkInvariants(t, st)
+	checkInvariants(t, c)
+}
+
+func TestSplit(t *testing.T) {
+	st := New()
+	for i := 0; i < 103; i++ {
+		st.Put(i, fmt.Sprint(i))
+	}
+
+	parts := st.Split(4)
+	if len(parts) != 4 {
+		t.Fatalf("Expected 4 parts, got %d", len(parts))
+	}
+
+	seen := make(map[any]any)
+	for _, part := range parts {
+		if part.Size() < 103/4 || part.Size() > 103/4+1 {
+			t.Errorf("Unbalanced part size %d", part.Size())
+		}
+		for _, e := range part.entries {
+			if e.key == nil {
+				continue
+			}
+			if _, dup := seen[e.key]; dup {
+				t.Errorf("Key %v appears in more than one part", e.key)
+			}
+			seen[e.key] = e.value
+		}
+	}
+
+	if len(seen) != st.Size() {
+		t.Errorf("Expected %d keys across parts, got %d", st.Size(), len(seen))
+	}
+	for k, v := range seen {
+		if orig, ok := st.Get(k); !ok || orig != v {
+			t.Errorf("Key %v: part has %v, original has (%v, %v)", k, v, orig, ok)
+		}
+	}
+
+	if st.Size() != 103 {
+		t.Errorf("Expected original to stay at 103 entries, got %d", st.Size())
+	}
+	if parts := st.Split(0); parts != nil {
+		t.Errorf("Expected nil for n=0, got %v", parts)
+	}
+}
+
+// lengthNeq reports string keys of different lengths as unequal
+func lengthNeq(stored, query any) bool {
+	a, ok1 := stored.(string)
+	b, ok2 := query.(string)
+	return ok1 && ok2 && len(a) != len(b)
+}
+
+func TestSetCheapNeq(t *testing.T) {
+	st := New()
+	st.SetCheapNeq(lengthNeq)
+	for i := 0; i < 200; i++ {
+		st.Put(strings.Repeat("k", i), i)
+	}
+	for i := 0; i < 200; i++ {
+		if v, ok := st.Get(strings.Repeat("k", i)); !ok || v != i {
+			t.Errorf("Expected (%d, true), got (%v, %v)", i, v, ok)
+		}
+	}
+	if st.Size() != 200 {
+		t.Errorf("Expected size 200, got %d", st.Size())
+	}
+}
+
+func BenchmarkPreDistributed(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	keys := make([]uint64, 1000)
+	for i := range keys {
+		keys[i] = rng.Uint64()
+	}
+
+	for _, tc := range []struct {
+		name string
+		new  func() *SwissTable
+	}{
+		{"maphash", New},
+		{"predistributed", NewPreDistributed},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			st := tc.new()
+			for _, k := range keys {
+				st.Put(k, k)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				st.Get(keys[i%len(keys)])
+			}
+		})
+	}
+}
+
+func TestSwap(t *testing.T) {
+	st := New()
+	st.Put(1, "a")
+	if old, loaded := st.Swap(1, "b"); !loaded || old != "a" {
+		t.Errorf("Expected (a, true), got (%v, %v)", old, loaded)
+	}
+	if v, _ := st.Get(1); v != "b" {
+		t.Errorf("Expected the swapped value b, got %v", v)
+	}
+	if old, loaded := st.Swap(2, "c"); loaded || old != nil {
+		t.Errorf("Expected (nil, false) for a fresh key, got (%v, %v)", old, loaded)
+	}
+	if st.Size() != 2 {
+		t.Errorf("Expected size 2, got %d", st.Size())
+	}
+}
+
+func TestLoadOrStore(t *testing.T) {
+	calls := 0
+	st, _ := NewBuilder().WithHasher(func(key any) uint64 {
+		calls++
+		h, _ := preDistributedHash(key)
+		return h
+	}).Build()
+
+	st.Put(1, "one")
+	calls = 0
+	if actual, loaded := st.LoadOrStore(1, "uno"); !loaded || actual != "one" {
+		t.Errorf("Expected (one, true) for a present key, got (%v, %v)", actual, loaded)
+	}
+	if actual, loaded := st.LoadOrStore(2, "two"); loaded || actual != "two" {
+		t.Errorf("Expected (two, false) for a new key, got (%v, %v)", actual, loaded)
+	}
+	if calls != 2 {
+		t.Errorf("Expected one hash per call, got %d for 2 calls", calls)
+	}
+	if v, _ := st.Get(1); v != "one" {
+		t.Errorf("Expected LoadOrStore not to overwrite, got %v", v)
+	}
+	if v, _ := st.Get(2); v != "two" {
+		t.Errorf("Expected the new key to be stored, got %v", v)
+	}
+
+	groups := st.groupCount
+	for i := 3; i < 200; i++ {
+		st.LoadOrStore(i, i)
+	}
+	if st.groupCount == groups || st.Size() != 199 {
+		t.Errorf("Expected LoadOrStore to grow the table to hold 199 keys, got %d keys in %d groups", st.Size(), st.groupCount)
+	}
+	checkInvariants(t, st)
+}
+
+func TestPutHashesOnce(t *testing.T) {
+	calls := 0
+	st, _ := NewBuilder().WithCapacity(1000).WithHasher(func(key any) uint64 {
+		calls++
+		h, _ := preDistributedHash(key)
+		return h
+	}).Build()
+
+	for i := 0; i < 1000; i++ {
+		st.Put(i, i)
+	}
+	if calls != 1000 {
+		t.Errorf("Expected one hash per new key, got %d hashes for 1000 puts", calls)
+	}
+
+	calls = 0
+	for i := 0; i < 1000; i++ {
+		st.Put(i, -i)
+	}
+	if calls != 1000 {
+		t.Errorf("Expected one hash per update, got %d hashes for 1000 puts", calls)
+	}
+}
+
+func BenchmarkPut1M(b *testing.B) {
+	const n = 1 << 20
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		st := New()
+		for k := 0; k < n; k++ {
+			st.Put(k, k)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/put")
+}
+
+func BenchmarkCheapNeq(b *testing.B) {
+	// Long keys of varying length that share a common prefix
+	prefix := strings.Repeat("x", 1024)
+	keys := make([]string, 512)
+	for i := range keys {
+		keys[i] = prefix + strings.Repeat("y", i)
+	}
+
+	for _, withCheck := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cheapNeq=%v", withCheck), func(b *testing.B) {
+			st := New()
+			for i, k := range keys {
+				st.Put(k, i)
+			}
+
+			// Every H2 candidate goes through the hook; those it cannot
+			// reject fall through to the full compare
+			var candidates, full int
+			st.SetCheapNeq(func(stored, query any) bool {
+				candidates++
+				if withCheck && lengthNeq(stored, query) {
+					return true
+				}
+				full++
+				return false
+			})
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				st.Get(keys[i%len(keys)])
+			}
+			b.ReportMetric(float64(candidates)/float64(b.N), "candidates/op")
+			b.ReportMetric(float64(full)/float64(b.N), "fullcmp/op")
+		})
+	}
+}
+
+func TestPutMeta(t *testing.T) {
+	st := New()
+	st.PutMeta("a", 1, "created-at-noon")
+	st.Put("b", 2)
+
+	if v, meta, ok := st.GetMeta("a"); !ok || v != 1 || meta != "created-at-noon" {
+		t.Errorf("Expected (1, created-at-noon, true), got (%v, %v, %v)", v, meta, ok)
+	}
+	if v, meta, ok := st.GetMeta("b"); !ok || v != 2 || meta != nil {
+		t.Errorf("Expected (2, nil, true), got (%v, %v, %v)", v, meta, ok)
+	}
+	if _, _, ok := st.GetMeta("missing"); ok {
+		t.Error("Expected missing key to report ok=false")
+	}
+
+	// Metadata must survive the rehash triggered by growth
+	for i := 0; i < 100; i++ {
+		st.Put(i, i)
+	}
+	if _, meta, _ := st.GetMeta("a"); meta != "created-at-noon" {
+		t.Errorf("Expected metadata to survive resize, got %v", meta)
+	}
+
+	st.Put("a", 3)
+	if v, meta, _ := st.GetMeta("a"); v != 3 || meta != nil {
+		t.Errorf("Expected Put to drop metadata, got (%v, %v)", v, meta)
+	}
+}
+
+// checkInvariants verifies that control bytes, entries and size agree and
+// that every stored key can be found again
+func checkInvariants(t *testing.T, st *SwissTable) {
+	t.Helper()
+	live := 0
+	for idx, e := range st.entries {
+		ctrl := st.metadata[idx/groupSize].bytes[idx%groupSize]
+		if isFull(ctrl) != (e.key != nil) {
+			t.Errorf("Slot %d: control byte %d disagrees with entry %v", idx, ctrl, e.key)
+		}
+		if e.key == nil {
+			continue
+		}
+		live++
+		if _, h2 := st.hashKey(e.key); ctrl != h2 || e.h2Hash != h2 {
+			t.Errorf("Slot %d: stored H2 %d/%d, expected %d", idx, ctrl, e.h2Hash, h2)
+		}
+		if got, ok := st.Get(e.key); !ok || got != e.value {
+			t.Errorf("Key %v: expected (%v, true), got (%v, %v)", e.key, e.value, got, ok)
+		}
+	}
+	if live != st.size {
+		t.Errorf("Size %d, but %d live entries", st.size, live)
+	}
+	tombstones := 0
+	for i := range st.metadata {
+		if want := bits.OnesCount16(matchFull(&st.metadata[i])); st.occupancy[i] != want {
+			t.Errorf("Group %d: occupancy %d, but %d full slots", i, st.occupancy[i], want)
+		}
+		deleted := bits.OnesCount16(matchGroup(&st.metadata[i], ctrlDeleted))
+		if deleted > 0 && matchEmpty(&st.metadata[i]) != 0 {
+			t.Errorf("Group %d: %d tombstones next to an empty slot", i, deleted)
+		}
+		tombstones += deleted
+	}
+	if tombstones != st.tombstones {
+		t.Errorf("Tombstone count %d, but %d deleted slots", st.tombstones, tombstones)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	st := New()
+	for i := 0; i < 30; i++ {
+		st.Put(i, i*10)
+	}
+	if n := st.Repair(); n != 0 {
+		t.Errorf("Expected no repairs on a healthy table, got %d", n)
+	}
+
+	// Control byte without an entry
+	var ghost int
+	for ghost = range st.entries {
+		if st.entries[ghost].key == nil {
+			break
+		}
+	}
+	st.metadata[ghost/groupSize].bytes[ghost%groupSize] = 42
+
+	// Entry whose control byte was lost
+	lost, _ := st.SlotOf(7)
+	st.metadata[lost/groupSize].bytes[lost%groupSize] = ctrlEmpty
+
+	// Size counter drift
+	st.size += 5
+
+	if n := st.Repair(); n != 3 {
+		t.Errorf("Expected 3 repairs, got %d", n)
+	}
+	checkInvariants(t, st)
+	if st.Size() != 30 {
+		t.Errorf("Expected size 30 after repair, got %d", st.Size())
+	}
+	if v, ok := st.Get(7); !ok || v != 70 {
+		t.Errorf("Expected (70, true) for key with lost control byte, got (%v, %v)", v, ok)
+	}
+
+	// Without the rehash the counters are still recounted
+	st.FreezeCapacity()
+	lost, _ = st.SlotOf(3)
+	st.metadata[lost/groupSize].bytes[lost%groupSize] = ctrlEmpty
+	st.size -= 2
+	if n := st.Repair(); n != 2 {
+		t.Errorf("Expected 2 repairs under a frozen capacity, got %d", n)
+	}
+	checkInvariants(t, st)
+}
+
+// maxProbeLength returns the largest number of groups any live key probes
+func maxProbeLength(st *SwissTable) int {
+	longest := 0
+	for idx, e := range st.entries {
+		if e.key == nil {
+			continue
+		}
+		h1, _ := st.hashKey(e.key)
+		longest = max(longest, st.probeDistance(h1, idx)+1)
+	}
+	return longest
+}
+
+func TestRobinHoodLowersMaxProbe(t *testing.T) {
+	naive := New()
+	naive.resizeTo(128)
+	rh := New()
+	rh.hashSeed = naive.hashSeed
+	rh.resizeTo(128)
+	rh.EnableRobinHood()
+
+	// Fill groups 1-3 from their home, then pile keys onto group 0 so the
+	// naive layout pushes them far past the crowded neighbours
+	var keys []int
+	for _, home := range []uint64{1, 0} {
+		found := 0
+		for k := 0; found < 44; k++ {
+			if h1, _ := naive.hashKey(k); h1%8 == home {
+				keys = append(keys, k)
+				found++
+			}
+		}
+	}
+	for _, k := range keys {
+		naive.Put(k, k)
+		rh.Put(k, k)
+	}
+
+	if len(rh.entries) != 128 || len(naive.entries) != 128 {
+		t.Fatalf("Expected no resize, got %d and %d slots", len(naive.entries), len(rh.entries))
+	}
+	naiveMax, rhMax := maxProbeLength(naive), maxProbeLength(rh)
+	if rhMax >= naiveMax {
+		t.Errorf("Expected Robin Hood max probe below naive %d, got %d", naiveMax, rhMax)
+	}
+	checkInvariants(t, rh)
+}
+
+func TestRangeSince(t *testing.T) {
+	st := New()
+	for i := 0; i < 20; i++ {
+		st.Put(i, "old")
+	}
+	marker := st.Generation()
+
+	// New keys plus an update of an old one, enough to force a resize
+	want := map[any]any{5: "updated"}
+	for i := 100; i < 130; i++ {
+		st.Put(i, "new")
+		want[i] = "new"
+	}
+	st.Put(5, "updated")
+
+	got := make(map[any]any)
+	st.RangeSince(marker, func(k, v any) bool {
+		got[k] = v
+		return true
+	})
+	if len(got) != len(want) {
+		t.Errorf("Expected %d entries since marker, got %d", len(want), len(got))
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Key %v: expected %v, got %v", k, v, got[k])
+		}
+	}
+
+	visited := 0
+	st.RangeSince(marker, func(k, v any) bool {
+		visited++
+		return false
+	})
+	if visited != 1 {
+		t.Errorf("Expected early stop after 1 entry, visited %d", visited)
+	}
+
+	// Shrinking the table mid-iteration must not panic
+	st.RangeSince(marker, func(k, v any) bool {
+		st.DeleteFunc(func(key, value any) bool { return key != k })
+		st.TrimToFit()
+		return true
+	})
+}
+
+func TestNewInterned(t *testing.T) {
+	st := NewInterned()
+	first := string([]byte("shared-key"))
+	second := string([]byte("shared-key"))
+	if unsafe.StringData(first) == unsafe.StringData(second) {
+		t.Fatal("Test setup: expected distinct backing arrays")
+	}
+
+	st.Put(first, 1)
+	st.Put(second, 2)
+
+	slot, _ := st.SlotOf("shared-key")
+	stored := st.entries[slot].key.(string)
+	if unsafe.StringData(stored) != unsafe.StringData(first) {
+		t.Error("Expected the stored key to share the first key's storage")
+	}
+	if v, _ := st.Get("shared-key"); v != 2 {
+		t.Errorf("Expected value 2, got %v", v)
+	}
+
+	// A key re-added after deletion reuses the pooled string
+	st.Delete(second)
+	st.Put(string([]byte("shared-key")), 3)
+	slot, _ = st.SlotOf("shared-key")
+	if unsafe.StringData(st.entries[slot].key.(string)) != unsafe.StringData(first) {
+		t.Error("Expected re-inserted key to reuse the pooled string")
+	}
+}
+
+func TestFreezeCapacity(t *testing.T) {
+	st := New()
+	st.FreezeCapacity()
+	slots := len(st.entries)
+
+	var err error
+	inserted := 0
+	for ; inserted < 100; inserted++ {
+		if err = st.PutErr(inserted, inserted); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrCapacityFrozen) {
+		t.Fatalf("Expected ErrCapacityFrozen once the table is full, got %v", err)
+	}
+	if len(st.entries) != slots || st.Size() != inserted {
+		t.Errorf("Expected %d entries in %d slots, got %d in %d", inserted, slots, st.Size(), len(st.entries))
+	}
+	if err := st.PutErr(0, "updated"); err != nil {
+		t.Errorf("Expected updating an existing key to succeed, got %v", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected Put to panic when it would resize")
+			}
+		}()
+		st.Put(inserted, inserted)
+	}()
+
+	st.UnfreezeCapacity()
+	if err := st.PutErr(inserted, inserted); err != nil {
+		t.Errorf("Expected the insert to succeed after unfreezing, got %v", err)
+	}
+	if len(st.entries) <= slots {
+		t.Error("Expected the table to grow after unfreezing")
+	}
+	checkInvariants(t, st)
+}
+
+func TestResizeTo(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i)
+	}
+
+	for _, tc := range []struct{ capacity, want int }{
+		{1000, 1008},
+		{512, 512},
+		{140, 144},
+	} {
+		if err := st.ResizeTo(tc.capacity); err != nil {
+			t.Fatalf("ResizeTo(%d) failed: %v", tc.capacity, err)
+		}
+		if len(st.entries) != tc.want {
+			t.Errorf("ResizeTo(%d): expected %d slots, got %d", tc.capacity, tc.want, len(st.entries))
+		}
+		for i := 0; i < 100; i++ {
+			if v, ok := st.Get(i); !ok || v != i {
+				t.Errorf("ResizeTo(%d): key %d lost, got (%v, %v)", tc.capacity, i, v, ok)
+			}
+		}
+		checkInvariants(t, st)
+	}
+
+	if err := st.ResizeTo(128); err == nil {
+		t.Error("Expected an error for a capacity below the load factor")
+	}
+	if err := st.ResizeTo(0); err == nil {
+		t.Error("Expected an error for a zero capacity")
+	}
+	if len(st.entries) != 144 {
+		t.Errorf("Expected a rejected resize to keep 144 slots, got %d", len(st.entries))
+	}
+}
+
+func TestNextCapacity(t *testing.T) {
+	for _, st := range []*SwissTable{New(), NewPartitioned(3)} {
+		resizes := 0
+		for i := 0; i < 300; i++ {
+			before, next := len(st.entries), st.NextCapacity()
+			st.Put(i, i)
+			if len(st.entries) == before {
+				continue
+			}
+			resizes++
+			if len(st.entries) != next {
+				t.Errorf("Put %d: expected resize from %d to %d slots, got %d", i, before, next, len(st.entries))
+			}
+		}
+		if resizes == 0 {
+			t.Error("Expected at least one resize")
+		}
+	}
+
+	st := New()
+	want := "grow x2 when size exceeds 0.75 of 16 slots, next capacity 32 slots"
+	if got := st.ResizePolicy(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestWasteReport(t *testing.T) {
+	st := New()
+	used, total, minSlots := st.WasteReport()
+	if used != 0 || total != initialSize || minSlots != initialSize {
+		t.Errorf("Expected (0, %d, %d) on a new table, got (%d, %d, %d)",
+			initialSize, initialSize, used, total, minSlots)
+	}
+
+	for i := 0; i < 1000; i++ {
+		st.Put(i, i)
+	}
+	for i := 10; i < 1000; i++ {
+		st.Delete(i)
+	}
+
+	used, total, minSlots = st.WasteReport()
+	if used != 10 {
+		t.Errorf("Expected 10 used slots, got %d", used)
+	}
+	if total != len(st.entries) {
+		t.Errorf("Expected %d total slots, got %d", len(st.entries), total)
+	}
+	if minSlots != 16 {
+		t.Errorf("Expected 16 minimum slots for 10 entries, got %d", minSlots)
+	}
+	if total < 50*minSlots {
+		t.Errorf("Expected high waste, got %d total slots for a %d-slot minimum", total, minSlots)
+	}
+}
+
+func TestReplayLog(t *testing.T) {
+	st := New()
+	rnd := rand.New(rand.NewSource(99))
+	var ops []string
+	for i := 0; i < 300; i++ {
+		key := rnd.Intn(40)
+		switch operation(rnd.Intn(3)) {
+		case opPut:
+			value := rnd.Intn(1000)
+			st.Put(key, value)
+			ops = append(ops, fmt.Sprintf("Put(%v,%v)", key, value))
+		case opGet:
+			v, ok := st.Get(key)
+			ops = append(ops, fmt.Sprintf("Get(%v) = (%v, %v)", key, v, ok))
+		case opDelete:
+			ok := st.Delete(key)
+			ops = append(ops, fmt.Sprintf("Delete(%v) = %v", key, ok))
+		}
+	}
+
+	replayed := New()
+	if err := replayed.ReplayLog(ops); err != nil {
+		t.Fatalf("ReplayLog failed: %v", err)
+	}
+	if replayed.Size() != st.Size() {
+		t.Errorf("Expected size %d, got %d", st.Size(), replayed.Size())
+	}
+	for key := 0; key < 40; key++ {
+		want, wantOk := st.Get(key)
+		if got, ok := replayed.Get(key); ok != wantOk || got != want {
+			t.Errorf("Key %d: expected (%v, %v), got (%v, %v)", key, want, wantOk, got, ok)
+		}
+	}
+
+	spaced := New()
+	if err := spaced.ReplayLog([]string{"Put(1, 2)", "Put( 3 , x )", "Delete( 3 )"}); err != nil {
+		t.Fatalf("ReplayLog failed: %v", err)
+	}
+	if v, ok := spaced.Get(1); !ok || v != 2 {
+		t.Errorf("Expected spaces around arguments to be ignored, got (%#v, %v)", v, ok)
+	}
+	if spaced.Contains(3) || spaced.Size() != 1 {
+		t.Errorf("Expected Delete( 3 ) to remove key 3, got size %d", spaced.Size())
+	}
+
+	for _, bad := range []string{"Put(1)", "Frobnicate(1)", "Get 1"} {
+		if err := New().ReplayLog([]string{bad}); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := New(), New()
+	for i := 0; i < 100; i++ {
+		a.Put(i, "a")
+	}
+	for i := 50; i < 300; i++ {
+		b.Put(i, "b")
+	}
+
+	a.Merge(b)
+	if a.Size() != 300 {
+		t.Errorf("Expected 300 keys after merge, got %d", a.Size())
+	}
+	for i := 0; i < 300; i++ {
+		want := "b"
+		if i < 50 {
+			want = "a"
+		}
+		if v, ok := a.Get(i); !ok || v != want {
+			t.Errorf("Key %d: expected (%s, true), got (%v, %v)", i, want, v, ok)
+		}
+	}
+	if b.Size() != 250 {
+		t.Errorf("Expected the merged-in table to be unchanged, got size %d", b.Size())
+	}
+	checkInvariants(t, a)
+
+	sums, more := New(), New()
+	for i := 0; i < 20; i++ {
+		sums.Put(i, i)
+		more.Put(i+10, 100)
+	}
+	sums.MergeFunc(more, func(k, oldV, newV any) any { return oldV.(int) + newV.(int) })
+	for i := 0; i < 30; i++ {
+		want := i
+		switch {
+		case i >= 20:
+			want = 100
+		case i >= 10:
+			want = i + 100
+		}
+		if v, _ := sums.Get(i); v != want {
+			t.Errorf("Key %d: expected %d, got %v", i, want, v)
+		}
+	}
+}
+
+func TestMergeWithLog(t *testing.T) {
+	st, other := New(), New()
+	for i := 0; i < 50; i++ {
+		st.Put(i, i)
+	}
+	for i := 30; i < 80; i++ {
+		v := i
+		if i%10 == 0 {
+			v = -i
+		}
+		other.Put(i, v)
+	}
+
+	conflicts := st.MergeWithLog(other)
+	got := make(map[any]Conflict)
+	for _, c := range conflicts {
+		got[c.Key] = c
+	}
+	if len(conflicts) != 2 || len(got) != 2 {
+		t.Fatalf("Expected conflicts for keys 30 and 40 only, got %v", conflicts)
+	}
+	for _, k := range []int{30, 40} {
+		if want := (Conflict{Key: k, ReceiverValue: k, OtherValue: -k}); got[k] != want {
+			t.Errorf("Key %d: expected %v, got %v", k, want, got[k])
+		}
+	}
+
+	if st.Size() != 80 {
+		t.Errorf("Expected 80 keys after merge, got %d", st.Size())
+	}
+	for i := 0; i < 80; i++ {
+		want := i
+		if i >= 50 && i%10 == 0 {
+			want = -i
+		}
+		if v, ok := st.Get(i); !ok || v != want {
+			t.Errorf("Key %d: expected (%d, true), got (%v, %v)", i, want, v, ok)
+		}
+	}
+	checkInvariants(t, st)
+}
+
+func TestMergeStream(t *testing.T) {
+	first, second, third := New(), New(), New()
+	for i := 0; i < 10; i++ {
+		first.Put(i, "first")
+	}
+	for i := 5; i < 20; i++ {
+		second.Put(i, "second")
+	}
+	for i := 15; i < 25; i++ {
+		third.Put(i, "third")
+	}
+
+	got := make(map[any]any)
+	for k, v := range MergeStream(first, second, third) {
+		if _, dup := got[k]; dup {
+			t.Errorf("Key %v yielded more than once", k)
+		}
+		got[k] = v
+	}
+
+	if len(got) != 25 {
+		t.Errorf("Expected 25 distinct keys, got %d", len(got))
+	}
+	for k := 0; k < 25; k++ {
+		want := "third"
+		if k < 10 {
+			want = "first"
+		} else if k < 20 {
+			want = "second"
+		}
+		if got[k] != want {
+			t.Errorf("Key %d: expected value from %s, got %v", k, want, got[k])
+		}
+	}
+
+	n := 0
+	for range MergeStream(first, second) {
+		if n++; n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Errorf("Expected early break after 3 entries, got %d", n)
+	}
+
+	// Shrinking a table mid-stream must not panic
+	for k := range MergeStream(second, third) {
+		second.DeleteFunc(func(key, value any) bool { return key != k })
+		second.TrimToFit()
+	}
+}
+
+func TestSetKeyValidator(t *testing.T) {
+	errEmpty := errors.New("empty key")
+	st := New()
+	st.SetKeyValidator(func(key any) error {
+		if s, ok := key.(string); ok && s == "" {
+			return errEmpty
+		}
+		return nil
+	})
+
+	if err := st.PutErr("", 1); !errors.Is(err, errEmpty) {
+		t.Errorf("Expected %v, got %v", errEmpty, err)
+	}
+	if _, ok := st.Get(""); ok {
+		t.Error("Rejected key must not be stored")
+	}
+	if err := st.PutErr("ok", 2); err != nil {
+		t.Errorf("Expected valid key to be accepted, got %v", err)
+	}
+	if v, ok := st.Get("ok"); !ok || v != 2 {
+		t.Errorf("Expected (2, true), got (%v, %v)", v, ok)
+	}
+
+	defer func() {
+		if r := recover(); r != errEmpty {
+			t.Errorf("Expected Put to panic with %v, got %v", errEmpty, r)
+		}
+		if st.Size() != 1 {
+			t.Errorf("Expected size 1, got %d", st.Size())
+		}
+	}()
+	st.Put("", 3)
+}
+
+func TestSetMaxValueSize(t *testing.T) {
+	st := New()
+	st.SetMaxValueSize(1024, func(value any) int {
+		return len(value.([]byte))
+	})
+
+	if err := st.PutErr("big", make([]byte, 1025)); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Expected ErrValueTooLarge, got %v", err)
+	}
+	if _, ok := st.Get("big"); ok {
+		t.Error("Oversized value must not be stored")
+	}
+	if err := st.PutErr("fits", make([]byte, 1024)); err != nil {
+		t.Errorf("Expected value at the cap to be accepted, got %v", err)
+	}
+
+	st.SetMaxValueSize(0, nil)
+	if err := st.PutErr("big", make([]byte, 4096)); err != nil {
+		t.Errorf("Expected no cap after reset, got %v", err)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	st := New()
+	for i := 0; i < 20; i++ {
+		st.Put(fmt.Sprintf("k%d", i), i)
+	}
+
+	buckets := st.GroupBy(func(key, value any) any {
+		return value.(int) % 2
+	})
+	if len(buckets) != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", len(buckets))
+	}
+	for parity, values := range buckets {
+		if len(values) != 10 {
+			t.Errorf("Bucket %v: expected 10 values, got %d", parity, len(values))
+		}
+		for _, v := range values {
+			if v.(int)%2 != parity {
+				t.Errorf("Value %v landed in bucket %v", v, parity)
+			}
+		}
 	}
 
-	// Test overwrite
-	st.Put(1, "new-one")
-	if val, ok := st.Get(1); !ok || val != "new-one" {
-		t.Errorf("Expected (new-one, true), got (%v, %v)", val, ok)
+	if got := New().GroupBy(func(key, value any) any { return key }); len(got) != 0 {
+		t.Errorf("Expected no buckets for an empty table, got %v", got)
 	}
+}
 
-	// Test delete
-	if !st.Delete(1) {
-		t.Error("Delete should return true for existing key")
+func TestStats(t *testing.T) {
+	st := New()
+	st.resizeTo(8 * groupSize)
+	keys := clusteredKeys(st, 3*groupSize)
+	for _, k := range keys {
+		st.Put(k, k)
 	}
-	if val, ok := st.Get(1); ok {
-		t.Errorf("Expected not found after delete, got (%v, %v)", val, ok)
+	for _, k := range keys[:5] {
+		st.Delete(k)
+	}
+
+	s := st.Stats()
+	if s.Size != len(keys)-5 || s.Capacity != 8*groupSize || s.Groups != 8 || s.Tombstones != 5 {
+		t.Errorf("Expected size %d, 128 slots, 8 groups and 5 tombstones, got %+v", len(keys)-5, s)
+	}
+	if s.LoadFactor != float64(s.Size)/float64(s.Capacity) {
+		t.Errorf("Expected load factor %v, got %v", float64(s.Size)/float64(s.Capacity), s.LoadFactor)
+	}
+	// Three groups' worth of keys share one home group
+	if s.MaxProbeLength != 3 || s.AverageProbeLength <= 1 {
+		t.Errorf("Expected max probe length 3 and an average above 1, got %d and %v", s.MaxProbeLength, s.AverageProbeLength)
+	}
+
+	if s := New().Stats(); s.Size != 0 || s.MaxProbeLength != 0 || s.AverageProbeLength != 0 {
+		t.Errorf("Expected zero probe stats for an empty table, got %+v", s)
+	}
+}
+
+func TestQuickStats(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i)
+	}
+
+	size, capacity, load := st.QuickStats()
+	if size != st.Size() {
+		t.Errorf("Expected size %d, got %d", st.Size(), size)
+	}
+	if capacity != len(st.entries) {
+		t.Errorf("Expected capacity %d, got %d", len(st.entries), capacity)
+	}
+	if want := float64(size) / float64(capacity); load != want {
+		t.Errorf("Expected load %v, got %v", want, load)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { st.QuickStats() }); allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+// sameH2Keys returns n long string keys that all share an H2 under st's seed
+func sameH2Keys(st *SwissTable, n int) []string {
+	prefix := strings.Repeat("x", 1024)
+	_, target := st.hashKey(prefix + "0")
+	keys := make([]string, 0, n)
+	for i := 0; len(keys) < n; i++ {
+		k := prefix + strconv.Itoa(i)
+		if _, h2 := st.hashKey(k); h2 == target {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestFingerprintWithSharedH2(t *testing.T) {
+	st := New()
+	keys := sameH2Keys(st, 12)
+	for i, k := range keys {
+		st.Put(k, i)
+	}
+	for i, k := range keys {
+		if v, ok := st.Get(k); !ok || v != i {
+			t.Errorf("Expected (%d, true), got (%v, %v)", i, v, ok)
+		}
+	}
+	checkInvariants(t, st)
+}
+
+func BenchmarkFingerprint(b *testing.B) {
+	// Twelve expensive keys crammed into the single group, all sharing one
+	// H2, so every matchGroup bit in the group is a false positive candidate
+	st := New()
+	keys := sameH2Keys(st, 12)
+	for i, k := range keys {
+		st.Put(k, i)
+	}
+
+	// H2 candidates each lookup would fully compare without the fingerprint
+	h2Candidates := 0
+	for _, k := range keys {
+		_, h2 := st.hashKey(k)
+		slot, _ := st.SlotOf(k)
+		for m := matchGroup(&st.metadata[0], h2); m != 0; m &= m - 1 {
+			h2Candidates++
+			if bits.TrailingZeros16(m) == slot {
+				break
+			}
+		}
+	}
+
+	full := 0
+	st.SetCheapNeq(func(stored, query any) bool {
+		full++
+		return false
+	})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		st.Get(keys[i%len(keys)])
+	}
+	b.ReportMetric(float64(h2Candidates)/float64(len(keys)), "h2cand/op")
+	b.ReportMetric(float64(full)/float64(b.N), "fullcmp/op")
+}
+
+func TestKeyTypeHistogram(t *testing.T) {
+	type userID int
+	st := New()
+	for i := 0; i < 5; i++ {
+		st.Put(i, i)
+	}
+	for i := 0; i < 3; i++ {
+		st.Put(strconv.Itoa(i), i)
+	}
+	st.Put(userID(1), 1)
+	st.Put(nil, 0)
+
+	got := st.KeyTypeHistogram()
+	want := map[string]int{"int": 5, "string": 3, "swisstable.userID": 1, "<nil>": 1}
+	if len(got) != len(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	for name, n := range want {
+		if got[name] != n {
+			t.Errorf("Type %s: expected %d keys, got %d", name, n, got[name])
+		}
+	}
+}
+
+func TestGroupOccupancy(t *testing.T) {
+	for name, st := range map[string]*SwissTable{"default": New(), "robinhood": New(), "partitioned": NewPartitioned(4)} {
+		if name == "robinhood" {
+			st.EnableRobinHood()
+		}
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 3000; i++ {
+			k := rng.Intn(500)
+			switch rng.Intn(4) {
+			case 0:
+				st.Delete(k)
+			case 1:
+				st.SoftDelete(k)
+			default:
+				st.Put(k, i)
+			}
+		}
+		st.DrainIf(func(key, value any) bool { return key.(int)%5 == 0 })
+
+		occupancy := st.GroupOccupancy()
+		total := 0
+		for i, n := range occupancy {
+			if want := bits.OnesCount16(matchFull(&st.metadata[i])); n != want {
+				t.Errorf("%s: group %d: maintained count %d, recount %d", name, i, n, want)
+			}
+			total += n
+		}
+		if total != st.Size() {
+			t.Errorf("%s: occupancy sums to %d, size is %d", name, total, st.Size())
+		}
+		checkInvariants(t, st)
+
+		st.Clear()
+		for i, n := range st.GroupOccupancy() {
+			if n != 0 {
+				t.Errorf("%s: group %d: expected 0 after Clear, got %d", name, i, n)
+			}
+		}
+	}
+}
+
+func TestIsSkewed(t *testing.T) {
+	// Every key hashes to group 0 with a distinct H2, so the entries fill
+	// group 0 and spill into the next group only once it is full
+	hot, _ := NewBuilder().WithCapacity(200).WithHasher(func(key any) uint64 {
+		return uint64(key.(int)) % 128
+	}).Build()
+	for i := 0; i < 12; i++ {
+		hot.Put(i, i)
+	}
+	if !hot.IsSkewed(0.5) {
+		t.Errorf("Expected a single hot group to be skewed, occupancy %v", hot.GroupOccupancy())
+	}
+
+	st := New()
+	for i := 0; i < 1000; i++ {
+		st.Put(i, i)
+	}
+	if st.IsSkewed(0.5) {
+		t.Error("Expected well-distributed keys not to be skewed")
+	}
+	if New().IsSkewed(0.5) {
+		t.Error("Expected an empty table not to be skewed")
+	}
+}
+
+func TestExportMetadata(t *testing.T) {
+	st := New()
+	for i := 0; i < 40; i++ {
+		st.Put(i, i)
+	}
+
+	groups := st.ExportMetadata()
+	if len(groups) != st.groupCount {
+		t.Fatalf("Expected %d groups, got %d", st.groupCount, len(groups))
+	}
+	for i := range groups {
+		if groups[i] != st.metadata[i].bytes {
+			t.Errorf("Group %d: exported %v, table has %v", i, groups[i], st.metadata[i].bytes)
+		}
+	}
+
+	groups[0][0] ^= 0xFF
+	if groups[0][0] == st.metadata[0].bytes[0] {
+		t.Error("Modifying the export changed the table")
+	}
+	checkInvariants(t, st)
+}
+
+func TestGetBytes(t *testing.T) {
+	st := New()
+	stored := []byte("payload")
+	st.Put("blob", stored)
+	st.Put("text", "payload")
+
+	got, ok := st.GetBytes("blob")
+	if !ok || string(got) != "payload" {
+		t.Fatalf("Expected (payload, true), got (%q, %v)", got, ok)
+	}
+	if &got[0] != &stored[0] {
+		t.Error("Expected GetBytes to share the stored backing array")
+	}
+
+	if _, ok := st.GetBytes("text"); ok {
+		t.Error("Expected false for a non-byte-slice value")
+	}
+	if _, ok := st.GetBytes("missing"); ok {
+		t.Error("Expected false for a missing key")
+	}
+}
+
+func TestResizeFailureKeepsTable(t *testing.T) {
+	st := New()
+	for i := 0; i < 96; i++ {
+		st.Put(i, i)
+	}
+	if len(st.entries) != 128 {
+		t.Fatalf("Test setup: expected 128 slots, got %d", len(st.entries))
+	}
+
+	// Shrink every resize target far below what the entries need
+	resizeHook = func(newSize int) int { return newSize / 8 }
+	defer func() { resizeHook = nil }()
+
+	if err := st.resize(); err == nil {
+		t.Error("Expected resize into too few slots to fail")
+	}
+	if len(st.entries) != 128 || st.groupCount != 8 {
+		t.Errorf("Expected the old 128-slot layout, got %d slots in %d groups", len(st.entries), st.groupCount)
+	}
+	checkInvariants(t, st)
+
+	// Put keeps working on the old arrays while they still have room
+	st.Put(96, 96)
+	if v, ok := st.Get(96); !ok || v != 96 {
+		t.Errorf("Expected (96, true), got (%v, %v)", v, ok)
+	}
+	checkInvariants(t, st)
+
+	resizeHook = nil
+	for i := 97; i < 200; i++ {
+		st.Put(i, i)
+	}
+	checkInvariants(t, st)
+}
+
+func TestBatches(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i*10)
+	}
+
+	var all [][2]any
+	var sizes []int
+	for batch := range st.Batches(16) {
+		sizes = append(sizes, len(batch))
+		all = append(all, batch...)
+	}
+	for i, n := range sizes[:len(sizes)-1] {
+		if n != 16 {
+			t.Errorf("Batch %d: expected 16 entries, got %d", i, n)
+		}
+	}
+	if last := sizes[len(sizes)-1]; last != 100%16 {
+		t.Errorf("Expected a final batch of %d, got %d", 100%16, last)
+	}
+
+	var want [][2]any
+	st.Range(func(key, value any) bool {
+		want = append(want, [2]any{key, value})
+		return true
+	})
+	if !slices.Equal(all, want) {
+		t.Errorf("Expected batches to concatenate to all entries in slot order, got %v", all)
+	}
+
+	for range st.Batches(0) {
+		t.Error("Expected no batches for size 0")
+	}
+	for range st.Batches(7) {
+		break
+	}
+
+	// Shrinking the table mid-iteration must not panic
+	for range st.Batches(16) {
+		st.DeleteFunc(func(key, value any) bool { return key != 0 })
+		st.TrimToFit()
+	}
+	if len(st.entries) != initialSize {
+		t.Errorf("Expected the table to shrink to %d slots, got %d", initialSize, len(st.entries))
+	}
+}
+
+func TestScan(t *testing.T) {
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i*10)
+	}
+
+	seen := make(map[any]int)
+	pages := 0
+	var cursor uint64
+	for {
+		page, next := st.Scan(cursor, 7)
+		pages++
+		if len(page) > 7 {
+			t.Fatalf("Page %d: expected at most 7 entries, got %d", pages, len(page))
+		}
+		for _, kv := range page {
+			seen[kv[0]]++
+			if kv[1] != kv[0].(int)*10 {
+				t.Errorf("Key %v: expected value %d, got %v", kv[0], kv[0].(int)*10, kv[1])
+			}
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	if len(seen) != 100 {
+		t.Errorf("Expected 100 distinct keys, got %d", len(seen))
+	}
+	for k, n := range seen {
+		if n != 1 {
+			t.Errorf("Key %v visited %d times", k, n)
+		}
+	}
+	if pages < 100/7 {
+		t.Errorf("Expected at least %d pages, got %d", 100/7, pages)
+	}
+
+	if page, next := New().Scan(0, 10); len(page) != 0 || next != 0 {
+		t.Errorf("Expected empty scan of an empty table, got %v, %d", page, next)
+	}
+}
+
+func TestScanStaleCursor(t *testing.T) {
+	for name, mutate := range map[string]func(*SwissTable){
+		"Clear":  func(st *SwissTable) { st.Clear() },
+		"resize": func(st *SwissTable) { st.Reserve(1000) },
+		"insert": func(st *SwissTable) {
+			for i := 100; len(st.entries) == initialSize; i++ {
+				st.Put(i, i)
+			}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			st := New()
+			for i := 0; i < 10; i++ {
+				st.Put(i, i)
+			}
+			_, cursor := st.Scan(0, 3)
+
+			// Deletes keep the cursor valid
+			st.Delete(0)
+			if _, next := st.Scan(cursor, 3); next == 0 {
+				t.Fatal("Expected the scan to continue after a delete")
+			}
+
+			mutate(st)
+			if entries, next, err := st.ScanErr(cursor, 3); !errors.Is(err, ErrStaleCursor) || entries != nil || next != 0 {
+				t.Errorf("Expected ScanErr to return ErrStaleCursor, got (%v, %d, %v)", entries, next, err)
+			}
+			if _, _, err := st.ScanErr(0, 3); err != nil {
+				t.Errorf("Expected a fresh scan to succeed, got %v", err)
+			}
+			defer func() {
+				if err, _ := recover().(error); !errors.Is(err, ErrStaleCursor) {
+					t.Errorf("Expected a panic with ErrStaleCursor, got %v", err)
+				}
+			}()
+			st.Scan(cursor, 3)
+		})
+	}
+}
+
+func TestRange(t *testing.T) {
+	st := New()
+	want := make(map[any]any)
+	for i := 0; i < 200; i++ {
+		st.Put(i, i*10)
+		want[i] = i * 10
+	}
+	for i := 0; i < 200; i += 3 {
+		st.Delete(i)
+		delete(want, i)
+	}
+
+	got := make(map[any]any)
+	st.Range(func(key, value any) bool {
+		if _, dup := got[key]; dup {
+			t.Errorf("Key %v visited twice", key)
+		}
+		got[key] = value
+		return true
+	})
+	if len(got) != len(want) {
+		t.Errorf("Expected %d entries, visited %d", len(want), len(got))
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Key %v: expected %v, got %v", k, v, got[k])
+		}
+	}
+
+	visited := 0
+	st.Range(func(key, value any) bool {
+		visited++
+		return visited < 5
+	})
+	if visited != 5 {
+		t.Errorf("Expected early stop after 5 entries, visited %d", visited)
+	}
+
+	// Mutating during iteration, including a resize, must not panic
+	next := 1000
+	st.Range(func(key, value any) bool {
+		st.Delete(key)
+		for i := 0; i < 10; i++ {
+			st.Put(next, next)
+			next++
+		}
+		return true
+	})
+}
+
+func TestKeysValues(t *testing.T) {
+	empty := New()
+	if keys, values := empty.Keys(), empty.Values(); keys == nil || values == nil || len(keys) != 0 || len(values) != 0 {
+		t.Errorf("Expected non-nil empty slices, got %v and %v", keys, values)
+	}
+
+	st := New()
+	for i := 0; i < 100; i++ {
+		st.Put(i, i*10)
+	}
+	st.Delete(42)
+
+	keys, values := st.Keys(), st.Values()
+	if len(keys) != st.Size() || len(values) != st.Size() {
+		t.Fatalf("Expected %d keys and values, got %d and %d", st.Size(), len(keys), len(values))
+	}
+	for i, k := range keys {
+		if values[i] != k.(int)*10 {
+			t.Errorf("Index %d: value %v does not belong to key %v", i, values[i], k)
+		}
+		if k == 42 {
+			t.Error("Expected the deleted key to be absent")
+		}
+	}
+}
+
+func TestRangeReverse(t *testing.T) {
+	st := New()
+	for i := 0; i < 50; i++ {
+		st.Put(i, i)
+	}
+
+	var forward []any
+	for idx, e := range st.entries {
+		if isFull(st.metadata[idx/groupSize].bytes[idx%groupSize]) {
+			forward = append(forward, e.key)
+		}
+	}
+
+	var reverse []any
+	st.RangeReverse(func(key, value any) bool {
+		reverse = append(reverse, key)
+		return true
+	})
+
+	if len(reverse) != len(forward) {
+		t.Fatalf("Expected %d entries, got %d", len(forward), len(reverse))
+	}
+	for i := range forward {
+		if reverse[i] != forward[len(forward)-1-i] {
+			t.Fatalf("Position %d: expected %v, got %v", i, forward[len(forward)-1-i], reverse[i])
+		}
+	}
+
+	visited := 0
+	st.RangeReverse(func(key, value any) bool {
+		visited++
+		return visited < 5
+	})
+	if visited != 5 {
+		t.Errorf("Expected early stop after 5 entries, visited %d", visited)
+	}
+
+	// Shrinking the table mid-iteration must not panic
+	st.RangeReverse(func(key, value any) bool {
+		st.DeleteFunc(func(k, v any) bool { return k != key })
+		st.TrimToFit()
+		return true
+	})
+}
+
+func TestFingerprintCollisionRate(t *testing.T) {
+	// Every key gets H2 = 5 while H1 still spreads keys across groups
+	sameH2, err := NewBuilder().WithHasher(func(key any) uint64 {
+		return uint64(key.(int))<<h2Bits | 5
+	}).Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	for i := 0; i < 64; i++ {
+		sameH2.Put(i, i)
+	}
+	if rate := sameH2.FingerprintCollisionRate(); rate != 1 {
+		t.Errorf("Expected rate 1 with identical fingerprints, got %v", rate)
+	}
+
+	// Distinct H2 per key within each group
+	distinct, _ := NewBuilder().WithHasher(func(key any) uint64 {
+		return uint64(key.(int)%16 + 1)
+	}).Build()
+	for i := 0; i < 10; i++ {
+		distinct.Put(i, i)
+	}
+	if rate := distinct.FingerprintCollisionRate(); rate != 0 {
+		t.Errorf("Expected rate 0 with distinct fingerprints, got %v", rate)
+	}
+
+	if rate := New().FingerprintCollisionRate(); rate != 0 {
+		t.Errorf("Expected rate 0 for an empty table, got %v", rate)
+	}
+}
+
+func TestRename(t *testing.T) {
+	st := New()
+	st.PutMeta("old", 1, "tag")
+	st.Put("taken", 2)
+
+	if !st.Rename("old", "new") {
+		t.Fatal("Expected rename to succeed")
+	}
+	if _, ok := st.Get("old"); ok {
+		t.Error("Expected old key to be gone")
+	}
+	if v, meta, ok := st.GetMeta("new"); !ok || v != 1 || meta != "tag" {
+		t.Errorf("Expected (1, tag, true) under new key, got (%v, %v, %v)", v, meta, ok)
+	}
+	if st.Size() != 2 {
+		t.Errorf("Expected size 2, got %d", st.Size())
+	}
+
+	if st.Rename("missing", "other") {
+		t.Error("Expected rename of an absent key to fail")
+	}
+	if st.Rename("new", "taken") {
+		t.Error("Expected rename onto an existing key to fail")
+	}
+	if v, _ := st.Get("new"); v != 1 {
+		t.Errorf("Expected failed rename to leave new=1, got %v", v)
+	}
+	if v, _ := st.Get("taken"); v != 2 {
+		t.Errorf("Expected failed rename to leave taken=2, got %v", v)
+	}
+}
+
+func TestNewPartitioned(t *testing.T) {
+	const partitions = 4
+	st := NewPartitioned(partitions)
+	if st.groupCount != partitions {
+		t.Fatalf("Expected %d initial groups, got %d", partitions, st.groupCount)
+	}
+
+	for i := 0; i < 500; i++ {
+		st.Put(i, i)
+	}
+
+	span := st.groupCount / partitions
+	if st.groupCount%partitions != 0 {
+		t.Fatalf("Group count %d not divisible by %d partitions", st.groupCount, partitions)
+	}
+	for i := 0; i < 500; i++ {
+		slot, ok := st.SlotOf(i)
+		if !ok {
+			t.Fatalf("Key %d missing", i)
+		}
+		p := st.PartitionOf(i)
+		if group := slot / groupSize; group < p*span || group >= (p+1)*span {
+			t.Errorf("Key %d in partition %d sits in group %d, outside [%d, %d)",
+				i, p, group, p*span, (p+1)*span)
+		}
+	}
+	checkInvariants(t, st)
+
+	for i := 0; i < 500; i += 2 {
+		st.Delete(i)
+	}
+	for i := 0; i < 500; i++ {
+		if _, ok := st.Get(i); ok != (i%2 == 1) {
+			t.Errorf("Key %d: expected present=%v", i, i%2 == 1)
+		}
 	}
 }
 
@@ -101,6 +2707,7 @@ func TestSwissTableVsMap(t *testing.T) {
 					st.Put(key, value)
 					gm[key] = value
 					fmt.Printf("✅ Put completed\n")
+					ops = append(ops, fmt.Sprintf("Put(%v,%v)", key, value))
 
 				case opGet:
 					stVal, stOk := st.Get(key)
@@ -157,7 +2764,21 @@ func TestSwissTableVsMap(t *testing.T) {
 				if allMatch {
 					fmt.Printf("✅ All keys and values match\n")
 				}
-				fmt.Printf("\n" + strings.Repeat("-", 50) + "\n")
+				fmt.Print("\n" + strings.Repeat("-", 50) + "\n")
+			}
+
+			// The recorded ops alone must rebuild the final state
+			replayed := New()
+			if err := replayed.ReplayLog(ops); err != nil {
+				t.Fatalf("ReplayLog of the recorded ops failed: %v", err)
+			}
+			if replayed.Size() != len(gm) {
+				t.Errorf("Replayed size %d, expected %d", replayed.Size(), len(gm))
+			}
+			for k, gmVal := range gm {
+				if v, ok := replayed.Get(k); !ok || v != gmVal {
+					t.Errorf("Replayed key %v: expected (%v, true), got (%v, %v)", k, gmVal, v, ok)
+				}
 			}
 		})
 	}
@@ -185,14 +2806,13 @@ func formatOpList(ops []string) string {
 func mapToString(st *SwissTable) string {
 	result := "{"
 	first := true
-	for i := 0; i < len(st.entries); i++ {
-		if st.entries[i].key != nil {
-			if !first {
-				result += ", "
-			}
-			result += fmt.Sprintf("%v:%v", st.entries[i].key, st.entries[i].value)
-			first = false
+	st.Range(func(key, value any) bool {
+		if !first {
+			result += ", "
 		}
-	}
+		result += fmt.Sprintf("%v:%v", key, value)
+		first = false
+		return true
+	})
 	return result + "}"
 }